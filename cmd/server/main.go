@@ -25,11 +25,22 @@ func main() {
 		fx.Provide(service.NewAccountService),
 		fx.Provide(service.NewAcmeAccountService),
 		fx.Provide(service.NewAcmeCertService),
+		fx.Provide(service.NewAcmeDNSService),
+		fx.Provide(service.NewPropagationService),
 		fx.Provide(service.NewDNSService),
+		fx.Provide(service.NewAcmeOrderService),
+		fx.Provide(service.NewDomainService),
+		fx.Provide(service.NewImportService),
+		fx.Provide(service.NewAcmeJobService),
+		fx.Provide(service.NewOCSPService),
 		fx.Provide(service.NewStatisticsService),
+		fx.Provide(service.NewRenewalService),
 		fx.Provide(controller.NewAcmeAccountController),
 		fx.Provide(controller.NewAcmeCertController),
 		fx.Provide(controller.NewDNSController),
+		fx.Provide(controller.NewAcmeDNSController),
+		fx.Provide(controller.NewDomainController),
+		fx.Provide(controller.NewImportController),
 		fx.Provide(controller.NewAccountController),
 		fx.Provide(controller.NewStatisticsController),
 		fx.Provide(common.NewMigrationManager),
@@ -42,6 +53,7 @@ func main() {
 			}
 		}),
 		fx.Invoke(func(server *http.Server) {}),
+		fx.Invoke(registerBackgroundServices),
 	)
 	app.Run()
 }
@@ -52,7 +64,10 @@ func NewGinEngine(cfg *config.Config, logger *zap.Logger, middlewareManager *mid
 	b *controller.AcmeCertController,
 	c *controller.DNSController,
 	d *controller.AccountController,
-	statsCtl *controller.StatisticsController) *gin.Engine {
+	statsCtl *controller.StatisticsController,
+	acmeDNSCtl *controller.AcmeDNSController,
+	domainCtl *controller.DomainController,
+	importCtl *controller.ImportController) *gin.Engine {
 	// 设置Gin模式
 	if cfg.GetEnv() == "prod" {
 		gin.SetMode(gin.ReleaseMode)
@@ -60,6 +75,10 @@ func NewGinEngine(cfg *config.Config, logger *zap.Logger, middlewareManager *mid
 
 	// 创建引擎
 	engine := gin.Default()
+	// 只信任配置中的反向代理传递的 X-Forwarded-For，未配置时使用连接地址
+	if err := engine.SetTrustedProxies(cfg.App.TrustedProxies); err != nil {
+		logger.Error("invalid trusted_proxies", zap.Error(err))
+	}
 
 	// 应用所有中间件
 	middlewareManager.ApplyMiddlewares(engine)
@@ -87,23 +106,44 @@ func NewGinEngine(cfg *config.Config, logger *zap.Logger, middlewareManager *mid
 	// ACME账户管理路由（需要权限）
 	acmeAccountGroup := api.Group("/acme/accounts")
 	acmeAccountGroup.POST("", common.WithPermission(common.PermAcmeAccountCreate, a.NewAccount))
+	acmeAccountGroup.POST("/import", common.WithPermission(common.PermAcmeAccountCreate, a.ImportAccount))
 	acmeAccountGroup.GET("", common.WithPermission(common.PermAcmeAccountRead, a.GetAccounts))
 	acmeAccountGroup.GET("/:id", common.WithPermission(common.PermAcmeAccountRead, a.GetAccount))
+	acmeAccountGroup.GET("/:id/profiles", common.WithPermission(common.PermAcmeAccountRead, a.GetProfiles))
 	acmeAccountGroup.DELETE("/:id", common.WithPermission(common.PermAcmeAccountDelete, a.DeleteAcmeAccount))
 	acmeAccountGroup.POST("/:id/deactivate", common.WithPermission(common.PermAcmeAccountManage, a.DeactivateAcmeAccount))
+	acmeAccountGroup.POST("/:id/key-rollover", common.WithPermission(common.PermAcmeAccountManage, a.RolloverKey))
+	acmeAccountGroup.GET("/:id/keys", common.WithPermission(common.PermAcmeAccountRead, a.GetAccountKeys))
+	acmeAccountGroup.PUT("/:id/contacts", common.WithPermission(common.PermAcmeAccountManage, a.UpdateContacts))
+	acmeAccountGroup.POST("/:id/agree-tos", common.WithPermission(common.PermAcmeAccountManage, a.AgreeTermsOfService))
 
 	// ACME证书管理路由（需要权限）
 	acmeCertGroup := api.Group("/acme")
 	acmeCertGroup.POST("/certificates", common.WithPermission(common.PermAcmeCertCreate, b.NewCert))
+	acmeCertGroup.POST("/certificates/external", common.WithPermission(common.PermAcmeCertCreate, b.ImportExternalCert))
 	acmeCertGroup.GET("/certificates", common.WithPermission(common.PermAcmeCertRead, b.GetCerts))
 	acmeCertGroup.GET("/certificates/:id", common.WithPermission(common.PermAcmeCertRead, b.GetCert))
 	acmeCertGroup.DELETE("/certificates/:id", common.WithPermission(common.PermAcmeCertDelete, b.DeleteAcmeCert))
 	acmeCertGroup.POST("/certificates/:id/revoke", common.WithPermission(common.PermAcmeCertManage, b.RevokeCert))
+	acmeCertGroup.POST("/certificates/:id/renew", common.WithPermission(common.PermAcmeCertManage, b.RenewCert))
 	acmeCertGroup.GET("/certificates/:id/chain", common.WithPermission(common.PermAcmeCertRead, b.DownloadCertChain))
+	acmeCertGroup.PUT("/certificates/:id/key-policy", common.WithPermission(common.PermAcmeCertManage, b.UpdateKeyPolicy))
+	acmeCertGroup.PUT("/certificates/:id/dns-routes", common.WithPermission(common.PermAcmeCertManage, b.UpdateDNSRoutes))
+	acmeCertGroup.GET("/certificates/:id/ocsp", common.WithPermission(common.PermAcmeCertRead, b.DownloadOCSPResponse))
+	acmeCertGroup.POST("/certificates/:id/ocsp", common.WithPermission(common.PermAcmeCertManage, b.CheckOCSP))
+	acmeCertGroup.GET("/certificates/:id/chains", common.WithPermission(common.PermAcmeCertRead, b.ListCertChains))
+	acmeCertGroup.PUT("/certificates/:id/chain", common.WithPermission(common.PermAcmeCertManage, b.SwitchCertChain))
+	acmeCertGroup.GET("/certificates/:id/pair", common.WithPermission(common.PermAcmeCertPrivateKeyRead, b.DownloadCertPair))
+	acmeCertGroup.GET("/certificates/:id/versions", common.WithPermission(common.PermAcmeCertRead, b.ListCertVersions))
+	acmeCertGroup.POST("/certificates/:id/rollback", common.WithPermission(common.PermAcmeCertManage, b.RollbackCertVersion))
 	acmeCertGroup.GET("/certificates/:id/private_key", common.WithPermission(common.PermAcmeCertPrivateKeyRead, b.DownloadPrivateKey))
 	acmeCertGroup.GET("/certificates/:id/private-key-content", common.WithPermission(common.PermAcmeCertPrivateKeyRead, b.GetPrivateKey))
 	acmeCertGroup.POST("/auth", common.WithPermission(common.PermAcmeCertAuth, b.CreateAuth))
 	acmeCertGroup.POST("/auth/cert", common.WithPermission(common.PermAcmeCertAuth, b.GenCert))
+	acmeCertGroup.GET("/orders", common.WithPermission(common.PermAcmeCertAuth, b.GetPendingOrders))
+	acmeCertGroup.GET("/orders/:id/propagation", common.WithPermission(common.PermAcmeCertAuth, b.CheckOrderPropagation))
+	acmeCertGroup.GET("/jobs/:id", common.WithPermission(common.PermAcmeCertAuth, b.GetJob))
+	acmeCertGroup.POST("/jobs/:id/cancel", common.WithPermission(common.PermAcmeCertAuth, b.CancelJob))
 
 	// DNS提供商管理路由（需要权限）
 	dnsGroup := api.Group("/dns/provider")
@@ -114,6 +154,26 @@ func NewGinEngine(cfg *config.Config, logger *zap.Logger, middlewareManager *mid
 	dnsGroup.DELETE("/:id", common.WithPermission(common.PermDNSProviderDelete, c.DeleteDNSProvider))
 	dnsGroup.GET("/:id/secrets", common.WithPermission(common.PermDNSProviderSecretRead, c.GetDNSProviderSecrets))
 
+	// 受管域名路由（需要权限）
+	domainGroup := api.Group("/domains")
+	domainGroup.POST("", common.WithPermission(common.PermDomainCreate, domainCtl.NewDomain))
+	domainGroup.GET("", common.WithPermission(common.PermDomainRead, domainCtl.GetDomains))
+	domainGroup.GET("/:id", common.WithPermission(common.PermDomainRead, domainCtl.GetDomain))
+	domainGroup.PUT("/:id", common.WithPermission(common.PermDomainUpdate, domainCtl.UpdateDomain))
+	domainGroup.DELETE("/:id", common.WithPermission(common.PermDomainDelete, domainCtl.DeleteDomain))
+
+	// 从 certbot、acme.sh、lego 数据目录批量导入
+	api.POST("/import", common.WithPermission(common.PermAcmeImport, importCtl.Import))
+
+	// 内置acme-dns接口，update使用acme-dns账户认证，未开放注册时register需要创建DNS提供商的权限
+	acmeDNSRegister := acmeDNSCtl.Register
+	if !cfg.AcmeDNS.Enabled || !cfg.AcmeDNS.OpenRegistration {
+		acmeDNSRegister = common.WithPermission(common.PermDNSProviderCreate, acmeDNSCtl.Register)
+	}
+	api.POST("/acme-dns/register", acmeDNSRegister)
+	api.POST("/acme-dns/update", acmeDNSCtl.Update)
+	api.GET("/acme-dns/health", acmeDNSCtl.Health)
+
 	// 用户管理路由（需要权限）
 	userGroup := api.Group("/account/users")
 	userGroup.POST("", common.WithPermission(common.PermUserCreate, d.CreateUser))
@@ -170,6 +230,27 @@ func NewHttpServer(
 	return server
 }
 
+// registerBackgroundServices 将异步任务worker、证书自动续期、OCSP检查和内置acme-dns服务挂到应用生命周期
+func registerBackgroundServices(lc fx.Lifecycle, jobService service.AcmeJobService, renewalService service.RenewalService,
+	ocspService service.OCSPService, acmeDNSService service.AcmeDNSService) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			acmeDNSService.Start()
+			jobService.Start()
+			renewalService.Start()
+			ocspService.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			ocspService.Stop()
+			renewalService.Stop()
+			jobService.Stop()
+			acmeDNSService.Stop()
+			return nil
+		},
+	})
+}
+
 // runMigrations 执行数据库迁移
 func runMigrations(migrationManager *common.MigrationManager, logger *zap.Logger) error {
 	logger.Info("Starting migration")
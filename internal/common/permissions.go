@@ -17,6 +17,9 @@ const (
 	PermAcmeCertManage         = "acme:cert:manage"
 	PermAcmeCertPrivateKeyRead = "acme:cert:private_key:read"
 
+	// 批量导入账户和证书权限
+	PermAcmeImport = "acme:import"
+
 	// DNS提供商管理权限
 	PermDNSProviderCreate     = "dns:provider:create"
 	PermDNSProviderRead       = "dns:provider:read"
@@ -24,6 +27,12 @@ const (
 	PermDNSProviderDelete     = "dns:provider:delete"
 	PermDNSProviderSecretRead = "dns:provider:secret:read"
 
+	// 受管域名权限
+	PermDomainCreate = "dns:domain:create"
+	PermDomainRead   = "dns:domain:read"
+	PermDomainUpdate = "dns:domain:update"
+	PermDomainDelete = "dns:domain:delete"
+
 	// 用户管理权限
 	PermUserCreate = "user:create"
 	PermUserRead   = "user:read"
@@ -46,7 +55,9 @@ func GetAllPermissions() []string {
 		PermDashboardStats,
 		PermAcmeAccountCreate, PermAcmeAccountRead, PermAcmeAccountDelete, PermAcmeAccountManage,
 		PermAcmeCertCreate, PermAcmeCertRead, PermAcmeCertDelete, PermAcmeCertAuth, PermAcmeCertManage, PermAcmeCertPrivateKeyRead,
+		PermAcmeImport,
 		PermDNSProviderCreate, PermDNSProviderRead, PermDNSProviderUpdate, PermDNSProviderDelete, PermDNSProviderSecretRead,
+		PermDomainCreate, PermDomainRead, PermDomainUpdate, PermDomainDelete,
 		PermUserCreate, PermUserRead, PermUserUpdate, PermUserDelete,
 		PermRoleCreate, PermRoleRead, PermRoleUpdate, PermRoleDelete,
 		PermSystemPermissionRead,
@@ -7,9 +7,14 @@ import (
 )
 
 type Config struct {
-	App      AppConfig      `mapstructure:"app"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Log      LogConfig      `mapstructure:"log"`
+	App       AppConfig       `mapstructure:"app"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Log       LogConfig       `mapstructure:"log"`
+	Renewal   RenewalConfig   `mapstructure:"renewal"`
+	Challenge ChallengeConfig `mapstructure:"challenge"`
+	OCSP      OCSPConfig      `mapstructure:"ocsp"`
+	AcmeDNS   AcmeDNSConfig   `mapstructure:"acme_dns"`
+	Acme      AcmeConfig      `mapstructure:"acme"`
 }
 
 type AppConfig struct {
@@ -17,6 +22,8 @@ type AppConfig struct {
 	Port          int    `mapstructure:"port"`
 	SessionSecret string `mapstructure:"session_secret"`
 	Language      string `mapstructure:"language"`
+	// TrustedProxies 可信的反向代理地址或网段，只有来自这些地址的请求才使用 X-Forwarded-For 作为客户端IP
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type DatabaseConfig struct {
@@ -32,6 +39,46 @@ type LogConfig struct {
 	File  string `mapstructure:"file"`
 }
 
+type RenewalConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	IntervalMinutes int  `mapstructure:"interval_minutes"`  // 检查间隔（分钟）
+	RenewBeforeDays int  `mapstructure:"renew_before_days"` // 到期前多少天开始续期
+}
+
+type ChallengeConfig struct {
+	HTTP01Address string `mapstructure:"http01_address"` // HTTP-01 standalone 监听地址
+	HTTP01Webroot string `mapstructure:"http01_webroot"` // HTTP-01 webroot 默认目录
+	// HTTP01Webroots 允许证书使用的其他 webroot 目录，不在其中的目录会被拒绝
+	HTTP01Webroots   []string `mapstructure:"http01_webroots"`
+	TLSALPN01Address string   `mapstructure:"tlsalpn01_address"` // TLS-ALPN-01 监听地址
+	// DNSResolvers 查找区域和权威DNS服务器使用的递归DNS，为空时使用系统配置
+	DNSResolvers []string `mapstructure:"dns_resolvers"`
+	// PropagationTimeout 等待TXT记录在所有权威DNS服务器生效的最长时间（秒）
+	PropagationTimeout int `mapstructure:"propagation_timeout"`
+	// DisableCAACheck 关闭下单前的CAA检查
+	DisableCAACheck bool `mapstructure:"disable_caa_check"`
+}
+
+type OCSPConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	IntervalMinutes int  `mapstructure:"interval_minutes"` // 检查间隔（分钟）
+}
+
+type AcmeDNSConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	Listen           string `mapstructure:"listen"`            // DNS服务监听地址，同时监听UDP和TCP
+	Domain           string `mapstructure:"domain"`            // 委派给EasyACME的域名，如 acme.example.com
+	NSName           string `mapstructure:"nsname"`            // 该域名的NS记录，如 ns.acme.example.com
+	NSAdmin          string `mapstructure:"nsadmin"`           // SOA记录中的管理员邮箱，如 admin.example.com
+	IP               string `mapstructure:"ip"`                // Domain 和 NSName 的A记录，为空时不返回
+	OpenRegistration bool   `mapstructure:"open_registration"` // 是否允许未登录调用 /register，与 acme-dns 默认行为一致
+}
+
+type AcmeConfig struct {
+	// Servers 已知的CA目录地址，使用证书私钥吊销时只允许这些地址和已有账户所在的CA
+	Servers []string `mapstructure:"servers"`
+}
+
 // 为了兼容现有代码，保留这些字段
 func (c *Config) GetEnv() string           { return c.App.Env }
 func (c *Config) GetPort() int             { return c.App.Port }
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"easyacme/internal/common"
+	"easyacme/internal/model"
 	"easyacme/internal/service"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -49,6 +51,22 @@ func (s *AcmeAccountController) NewAccount(c *gin.Context) {
 	c.JSON(http.StatusOK, nil)
 }
 
+// ImportAccount 使用已有私钥导入CA上已存在的账户
+func (s *AcmeAccountController) ImportAccount(c *gin.Context) {
+	var req service.ImportAcmeAccountReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	account, err := s.acmeAccountService.ImportAcmeAccount(c.Request.Context(), &req)
+	if err != nil {
+		s.logger.Error("ImportAcmeAccount err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, account)
+}
+
 func (s *AcmeAccountController) GetAccounts(c *gin.Context) {
 	var req service.ListAccountReq
 	if err := c.ShouldBindQuery(&req); err != nil {
@@ -108,3 +126,104 @@ func (s *AcmeAccountController) DeleteAcmeAccount(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, nil)
 }
+
+// GetProfiles 查询账户所在CA支持的证书profile
+func (s *AcmeAccountController) GetProfiles(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	profiles, err := s.acmeAccountService.GetProfiles(c.Request.Context(), &service.GetProfilesReq{ID: id})
+	if err != nil {
+		s.logger.Error("GetProfiles err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, profiles)
+}
+
+// RolloverKey 为账户生成新密钥并在CA侧完成密钥轮换
+func (s *AcmeAccountController) RolloverKey(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+	var req service.RolloverKeyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentUser, _ := c.Get(common.CurrentUSer)
+	user, ok := currentUser.(*model.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+	req.ID = id
+	req.RetiredBy = user.ID
+
+	if err := s.acmeAccountService.RolloverKey(c.Request.Context(), &req); err != nil {
+		s.logger.Error("RolloverKey err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, nil)
+}
+
+// GetAccountKeys 查询账户的密钥轮换记录
+func (s *AcmeAccountController) GetAccountKeys(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	keys, err := s.acmeAccountService.ListAccountKeys(c.Request.Context(), &service.ListAccountKeysReq{ID: id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query err:" + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// UpdateContacts 更新账户联系邮箱并同步到CA
+func (s *AcmeAccountController) UpdateContacts(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+	var req service.UpdateAccountContactsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ID = id
+
+	if err := s.acmeAccountService.UpdateAccountContacts(c.Request.Context(), &req); err != nil {
+		s.logger.Error("UpdateAccountContacts err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, nil)
+}
+
+// AgreeTermsOfService 同意CA当前的服务条款
+func (s *AcmeAccountController) AgreeTermsOfService(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	if err := s.acmeAccountService.AgreeTermsOfService(c.Request.Context(), &service.AgreeTermsOfServiceReq{ID: id}); err != nil {
+		s.logger.Error("AgreeTermsOfService err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, nil)
+}
@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"crypto/x509"
-	"easyacme/internal/config"
+	"easyacme/internal/common"
 	"easyacme/internal/model"
 	"easyacme/internal/service"
 	"encoding/pem"
@@ -11,7 +14,7 @@ import (
 	"github.com/go-acme/lego/v4/challenge/resolver"
 	"github.com/go-acme/lego/v4/log"
 	"github.com/go-acme/lego/v4/platform/wait"
-	"net"
+	"io"
 	"net/http"
 	"reflect"
 	"strconv"
@@ -28,13 +31,6 @@ import (
 	"github.com/go-acme/lego/v4/challenge"
 	"github.com/go-acme/lego/v4/challenge/dns01"
 	"github.com/go-acme/lego/v4/lego"
-	"github.com/go-acme/lego/v4/providers/dns/alidns"
-	"github.com/go-acme/lego/v4/providers/dns/baiducloud"
-	"github.com/go-acme/lego/v4/providers/dns/cloudflare"
-	"github.com/go-acme/lego/v4/providers/dns/godaddy"
-	"github.com/go-acme/lego/v4/providers/dns/huaweicloud"
-	"github.com/go-acme/lego/v4/providers/dns/route53"
-	"github.com/go-acme/lego/v4/providers/dns/tencentcloud"
 	"github.com/go-acme/lego/v4/registration"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -47,26 +43,35 @@ type AcmeCertController struct {
 	acmeAccountService service.AcmeAccountService
 	acmeCertService    service.AcmeCertService
 	dnsService         service.DNSService
-	cache              config.Cache
+	acmeOrderService   service.AcmeOrderService
+	acmeJobService     service.AcmeJobService
+	propagationService service.PropagationService
+	domainService      service.DomainService
 }
 
 // NewAcmeCertController .
 func NewAcmeCertController(db *gorm.DB, logger *zap.Logger, acmeAccountService service.AcmeAccountService,
-	acmeCertService service.AcmeCertService, cache config.Cache, dnsService service.DNSService) *AcmeCertController {
+	acmeCertService service.AcmeCertService, dnsService service.DNSService, acmeOrderService service.AcmeOrderService,
+	acmeJobService service.AcmeJobService, propagationService service.PropagationService, domainService service.DomainService) *AcmeCertController {
 	return &AcmeCertController{
 		db:                 db,
 		logger:             logger,
 		acmeAccountService: acmeAccountService,
 		acmeCertService:    acmeCertService,
-		cache:              cache,
 		dnsService:         dnsService,
+		acmeOrderService:   acmeOrderService,
+		acmeJobService:     acmeJobService,
+		propagationService: propagationService,
+		domainService:      domainService,
 	}
 }
 
 type NewCertReq struct {
-	KeyType   certcrypto.KeyType `json:"key_type"`
-	AccountID string             `json:"account_id"`
-	Domains   []string           `json:"domains"`
+	KeyType     certcrypto.KeyType `json:"key_type"`
+	AccountID   string             `json:"account_id"`
+	Domains     []string           `json:"domains"`
+	Identifiers []model.Identifier `json:"identifiers"` // 带类型的标识，不为空时优先于 Domains
+	MustStaple  bool               `json:"must_staple"`
 }
 
 func (s *AcmeCertController) NewCert(c *gin.Context) {
@@ -76,6 +81,13 @@ func (s *AcmeCertController) NewCert(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	identifiers, err := service.ParseIdentifiers(req.Identifiers, req.Domains)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Domains = identifiers.Values()
+
 	account, err := s.acmeAccountService.GetAccount(c.Request.Context(), &service.GetAccountReq{ID: req.AccountID})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -117,7 +129,7 @@ func (s *AcmeCertController) NewCert(c *gin.Context) {
 	// }
 
 	// 申请证书
-	r := certificate.ObtainRequest{Domains: req.Domains, Bundle: true, MustStaple: false}
+	r := certificate.ObtainRequest{Domains: req.Domains, Bundle: true, MustStaple: req.MustStaple}
 	certRes, err := client.Certificate.Obtain(r)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -133,12 +145,12 @@ func (s *AcmeCertController) NewCert(c *gin.Context) {
 
 	id := uuid.New().String()
 	// 解析证书信息
-	certInfo := s.parseCertInfo(string(certRes.Certificate))
+	certInfo := service.ParseCertInfo(s.logger, string(certRes.Certificate))
 
 	err = s.db.Create(&model.AcmeCert{Model: model.Model{ID: id, CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		Domains:   req.Domains,
+		Domains: req.Domains, Identifiers: identifiers,
 		KeyType:   req.KeyType,
-		AccountID: req.AccountID, DNSProviderID: "cs", CertType: certInfo.CertType, IssuedAt: certInfo.IssuedAt, ValidityDays: certInfo.ValidityDays,
+		AccountID: req.AccountID, MustStaple: req.MustStaple, CertType: certInfo.CertType, IssuedAt: certInfo.IssuedAt, ValidityDays: certInfo.ValidityDays,
 		CertURL: certRes.CertURL, CertStableURL: certRes.CertStableURL,
 		PrivateKey: string(pemStr), Certificate: string(certRes.Certificate), IssuerCertificate: string(certRes.IssuerCertificate),
 		CSR: string(certRes.CSR),
@@ -147,6 +159,10 @@ func (s *AcmeCertController) NewCert(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if _, err := s.acmeCertService.CreateCertVersion(c.Request.Context(), &service.CreateCertVersionReq{CertID: id}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, nil)
 }
@@ -187,9 +203,11 @@ func (s *AcmeCertController) GetCert(c *gin.Context) {
 }
 
 type AuthReq struct {
-	KeyType   certcrypto.KeyType `json:"key_type"`
-	AccountID string             `json:"account_id"`
-	Domains   []string           `json:"domains"`
+	KeyType     certcrypto.KeyType `json:"key_type"`
+	AccountID   string             `json:"account_id"` // 为空时使用受管域名的默认账户
+	Domains     []string           `json:"domains"`
+	Identifiers []model.Identifier `json:"identifiers"` // 带类型的标识，不为空时优先于 Domains
+	Profile     string             `json:"profile"`     // CA证书profile，可选值见 /acme/accounts/:id/profiles
 }
 
 func (s *AcmeCertController) CreateAuth(c *gin.Context) {
@@ -200,49 +218,53 @@ func (s *AcmeCertController) CreateAuth(c *gin.Context) {
 		return
 	}
 
-	account, err := s.acmeAccountService.GetAccount(c.Request.Context(), &service.GetAccountReq{ID: req.AccountID})
+	identifiers, err := service.ParseIdentifiers(req.Identifiers, req.Domains)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// 还原私钥
-	block, _ := pem.Decode([]byte(account.KeyPem))
-	if block == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid private key"})
+	// 手动授权只支持DNS-01
+	if identifiers.HasIP() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrIPRequiresHTTPOrALPN.Error()})
 		return
 	}
-	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Parse private key failed"})
-		return
+	req.Domains = identifiers.Values()
+	if req.AccountID == "" {
+		defaults, err := s.domainService.ResolveIssueDefaults(c.Request.Context(), &service.ResolveIssueDefaultsReq{Domains: req.Domains})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.AccountID = defaults.AccountID
 	}
 
-	user := &service.User{
-		Email:        account.Email,
-		Registration: (*registration.Resource)(account.Registration),
-		Key:          privKey,
+	currentUser, _ := c.Get(common.CurrentUSer)
+	user, ok := currentUser.(*model.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
 	}
-	conf := lego.NewConfig(user)
-	conf.CADirURL = account.Server
-	client, err := lego.NewClient(conf)
+
+	core, err := s.newManualCore(c.Request.Context(), req.AccountID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Create client failed"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	err = client.Challenge.SetDNS01Provider(&ManualDNSProvider{})
+
+	account, err := s.acmeAccountService.GetAccount(c.Request.Context(), &service.GetAccountReq{ID: req.AccountID})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置 DNS-01 ManualDNSProvider 失败: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	core := getCertifierCore(client.Certificate)
-	if core == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get core"})
+	err = s.acmeCertService.CheckCAA(c.Request.Context(), &service.CheckCAAReq{Domains: req.Domains, Solver: model.SolverDNS01,
+		CAAIdentities: core.GetDirectory().Meta.CaaIdentities, AccountURI: service.AccountURI(account)})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	orderOpts := &api.OrderOptions{
-		Profile:        "",
+		Profile:        req.Profile,
 		ReplacesCertID: "",
 	}
 	order, err := core.Orders.NewWithOptions(req.Domains, orderOpts)
@@ -259,18 +281,101 @@ func (s *AcmeCertController) CreateAuth(c *gin.Context) {
 		}
 		authz = append(authz, authorization)
 	}
-	fmt.Println(authz)
 
-	infoList, err := getInfos(core, authz)
+	authorizations, err := getAuthorizations(core, order.Authorizations, authz)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"get info error": err.Error()})
 		return
 	}
-	id := fmt.Sprintf("%s:%s:%s", req.KeyType, req.AccountID, strings.Join(req.Domains, ","))
-	val := &Value{Id: id, client: client, core: core, order: order, authz: authz, InfoList: infoList}
-	s.cache.Set(id, val, 10*time.Minute)
 
-	c.JSON(http.StatusOK, val)
+	acmeOrder := &model.AcmeOrder{Model: model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		AccountID: req.AccountID, KeyType: req.KeyType, Domains: req.Domains, Identifiers: identifiers, Profile: req.Profile,
+		OrderURL: order.Location, FinalizeURL: order.Finalize, Status: order.Status, ExpiresAt: parseOrderExpires(order.Expires),
+		CreatedBy: user.ID, Authorizations: authorizations,
+	}
+	if err := s.acmeOrderService.CreateOrder(c.Request.Context(), acmeOrder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newValue(acmeOrder))
+}
+
+// GetPendingOrders 查询当前用户未完成的手动验证订单
+func (s *AcmeCertController) GetPendingOrders(c *gin.Context) {
+	var req service.ListPendingOrderReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentUser, _ := c.Get(common.CurrentUSer)
+	user, ok := currentUser.(*model.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+	req.CreatedBy = user.ID
+
+	resp, err := s.acmeOrderService.ListPendingOrders(c.Request.Context(), &req)
+	if err != nil {
+		s.logger.Error("ListPendingOrders err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CheckOrderPropagation 查询手动订单中每条TXT记录在各权威DNS服务器上的状态
+func (s *AcmeCertController) CheckOrderPropagation(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	currentUser, _ := c.Get(common.CurrentUSer)
+	user, ok := currentUser.(*model.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+
+	order, err := s.acmeOrderService.GetOrder(c.Request.Context(), &service.GetOrderReq{ID: id, CreatedBy: user.ID})
+	if err != nil {
+		s.logger.Error("CheckOrderPropagation GetOrder err: " + err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	records := make([]*model.DNSRecordStatus, 0, len(order.Authorizations))
+	for _, info := range order.Authorizations {
+		records = append(records, s.propagationService.Check(c.Request.Context(), &service.CheckPropagationReq{
+			Domain: info.Domain, FQDN: info.EffectiveFQDN, Value: info.TXTValue}))
+	}
+	c.JSON(http.StatusOK, gin.H{"resolvers": s.propagationService.Resolvers(), "records": records})
+}
+
+// newManualCore 创建使用手动DNS-01验证的ACME客户端，并返回其内部的 api.Core
+func (s *AcmeCertController) newManualCore(ctx context.Context, accountID string) (*api.Core, error) {
+	account, err := s.acmeAccountService.GetAccount(ctx, &service.GetAccountReq{ID: accountID})
+	if err != nil {
+		return nil, err
+	}
+	client, err := service.NewLegoClient(ctx, account, "")
+	if err != nil {
+		return nil, err
+	}
+	err = client.Challenge.SetDNS01Provider(&ManualDNSProvider{})
+	if err != nil {
+		return nil, fmt.Errorf("设置 DNS-01 ManualDNSProvider 失败: %w", err)
+	}
+
+	core := getCertifierCore(client.Certificate)
+	if core == nil {
+		return nil, errors.New("Failed to get core")
+	}
+	return core, nil
 }
 
 type ManualDNSProvider struct {
@@ -290,12 +395,31 @@ func (d *ManualDNSProvider) CleanUp(domain, token, keyAuth string) error {
 }
 
 type GenCertReq struct {
-	KeyType       certcrypto.KeyType `json:"key_type"`
-	AccountID     string             `json:"account_id"`
-	Domains       []string           `json:"domains"`
-	DNSProviderID string             `json:"dns_provider_id"`
+	OrderID       string                `json:"order_id"` // 手动验证时要继续的订单，为空时按账户、密钥类型和域名查找
+	KeyType       certcrypto.KeyType    `json:"key_type"`
+	AccountID     string                `json:"account_id"` // 为空时使用受管域名的默认账户和DNS提供商
+	Domains       []string              `json:"domains"`
+	Identifiers   []model.Identifier    `json:"identifiers"` // 带类型的标识，不为空时优先于 Domains
+	Solver        model.ChallengeSolver `json:"solver"`      // 为空时默认为 dns-01
+	DNSProviderID string                `json:"dns_provider_id"`
+	DNSRoutes     model.DNSRoutes       `json:"dns_routes"` // 按区域选择DNS提供商，未匹配的域名使用 DNSProviderID
+	Webroot       string                `json:"webroot"`    // http-01-webroot 使用的目录，为空时使用配置中的默认目录
+	// PreferredChain 首选证书链的顶级颁发者CN，如 "ISRG Root X1"，为空时使用CA默认链
+	PreferredChain string `json:"preferred_chain"`
+	// Profile CA证书profile，手动验证时使用创建订单时指定的profile
+	Profile string `json:"profile"`
+	// MustStaple 是否在证书中包含 OCSP Must-Staple 扩展，使用CSR时由CSR决定
+	MustStaple bool `json:"must_staple"`
+	// KeyPolicy 续期时的私钥策略，为空时默认每次续期更换私钥
+	KeyPolicy      model.KeyPolicy `json:"key_policy"`
+	KeyRotateEvery int             `json:"key_rotate_every"`
+	// CSR 用户提供的PEM格式证书请求，其中的域名需与 Domains 一致。使用CSR时服务器不生成也不保存私钥
+	CSR string `json:"csr"`
+	// Pair 同时签发RSA和ECDSA两张证书，忽略 KeyType，两张证书共用一轮域名验证并一起续期
+	Pair bool `json:"pair"`
 }
 
+// GenCert 提交签发任务，立即返回任务ID，签发进度通过 GetJob 查询
 func (s *AcmeCertController) GenCert(c *gin.Context) {
 	var req GenCertReq
 	// 绑定 JSON 请求体到 user 结构体
@@ -304,159 +428,371 @@ func (s *AcmeCertController) GenCert(c *gin.Context) {
 		return
 	}
 
-	var cert *certificate.Resource
-	// 如果是手动模式，先进行DNS预验证
-	if req.DNSProviderID == "" {
-		s.logger.Info("手动验证模式，开始DNS预验证")
-		id := fmt.Sprintf("%s:%s:%s", req.KeyType, req.AccountID, strings.Join(req.Domains, ","))
-		val, exist := s.cache.Get(id)
-		if !exist {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "授权信息已过期，请重新创建授权"})
+	if req.Solver == "" {
+		req.Solver = model.SolverDNS01
+	}
+	if !req.Solver.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid solver: " + string(req.Solver)})
+		return
+	}
+	if req.Pair && req.CSR != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "证书对不支持使用CSR签发"})
+		return
+	}
+	// webroot 只能是配置中的目录，为空时续期继续跟随默认目录
+	if req.Solver == model.SolverHTTP01Webroot {
+		if _, err := s.acmeCertService.ResolveWebroot(req.Webroot); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		value := val.(*Value)
-		// 对每个域名进行DNS预验证
-		for _, info := range value.InfoList {
-			if err := s.dnsPreCheck(info.EffectiveFQDN, info.Value); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error":          fmt.Sprintf("DNS预验证失败: %v", err),
-					"domain":         info.EffectiveFQDN,
-					"expected_value": info.Value,
-					"suggestion":     "请确保已正确设置DNS TXT记录，并等待DNS传播完成",
-				})
-				return
-			}
+	}
+	if req.KeyPolicy == "" {
+		req.KeyPolicy = model.KeyPolicyRotate
+	}
+	if err := service.ValidateKeyPolicy(req.KeyPolicy, req.KeyRotateEvery); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.OrderID == "" {
+		identifiers, err := service.ParseIdentifiers(req.Identifiers, req.Domains)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		s.logger.Info("DNS预验证通过，开始正式验证")
-		for _, authorization := range value.authz {
-			domain := challenge.GetTargetedDomain(authorization)
-			chall := acme.Challenge{}
-			for _, ch := range authorization.Challenges {
-				if ch.Type == string(challenge.DNS01) {
-					chall = ch
-				}
-			}
-			err := validate(value.core, domain, chall)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("域名 %s 验证失败: %v", domain, err)})
-				return
-			}
+		if identifiers.HasIP() && req.Solver == model.SolverDNS01 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrIPRequiresHTTPOrALPN.Error()})
+			return
 		}
-
-		solversManager := resolver.NewSolversManager(value.core)
-		err := solversManager.SetDNS01Provider(&ManualDNSProvider{})
+		req.Domains = identifiers.Values()
+	}
+	// 未指定账户时由受管域名补全账户和DNS提供商
+	if req.AccountID == "" && req.OrderID == "" {
+		defaults, err := s.domainService.ResolveIssueDefaults(c.Request.Context(), &service.ResolveIssueDefaultsReq{Domains: req.Domains})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "设置 DNS-01 ManualDNSProvider 失败: " + err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		prober := resolver.NewProber(solversManager)
-		err = prober.Solve(value.authz)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("域名 Solve失败: %v", err)})
+		req.AccountID = defaults.AccountID
+		if req.Solver == model.SolverDNS01 && req.DNSProviderID == "" && len(req.DNSRoutes) == 0 {
+			req.DNSProviderID, req.DNSRoutes = defaults.DNSProviderID, defaults.DNSRoutes
+		}
+	}
+	if len(req.DNSRoutes) > 0 {
+		if err := service.ValidateDNSRoutes(req.DNSRoutes, req.DNSProviderID, req.Domains); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+	}
+
+	currentUser, _ := c.Get(common.CurrentUSer)
+	user, ok := currentUser.(*model.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
 
-		s.logger.Info(strings.Join(req.Domains, ", ") + " acme: Validations succeeded; requesting certificates")
-		privateKey, err := certcrypto.GeneratePrivateKey(req.KeyType)
+	// 手动模式需要先找到之前创建的订单
+	var order *model.AcmeOrder
+	if req.Solver == model.SolverDNS01 && req.DNSProviderID == "" && len(req.DNSRoutes) == 0 {
+		var err error
+		if req.OrderID != "" {
+			order, err = s.acmeOrderService.GetOrder(c.Request.Context(), &service.GetOrderReq{ID: req.OrderID, CreatedBy: user.ID})
+		} else {
+			order, err = s.acmeOrderService.FindPendingOrder(c.Request.Context(), &service.FindPendingOrderReq{
+				AccountID: req.AccountID, KeyType: req.KeyType, Domains: req.Domains, CreatedBy: user.ID})
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建私钥失败: %v", err)})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "授权信息已过期，请重新创建授权"})
 			return
 		}
+		req.AccountID, req.KeyType, req.Domains, req.Profile = order.AccountID, order.KeyType, order.Domains, order.Profile
+	}
 
-		csrOptions := certcrypto.CSROptions{
-			Domain: req.Domains[0],
-			SAN:    req.Domains,
-		}
-
-		csr, err := certcrypto.CreateCSR(privateKey, csrOptions)
+	var csr *x509.CertificateRequest
+	if req.CSR != "" {
+		var err error
+		csr, err = service.ParseCSR(req.CSR, req.Domains)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建CSR失败: %v", err)})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		privateKeyPem := certcrypto.PEMEncode(privateKey)
-		cert, err = getForCSR(value.core, req.Domains, value.order, true, csr, privateKeyPem, "")
+		req.KeyType = service.CSRKeyType(csr)
+	}
+
+	job, err := s.acmeJobService.SubmitJob(c.Request.Context(), &service.SubmitJobReq{
+		Type: model.JobTypeIssue, Domains: req.Domains, CreatedBy: user.ID,
+	}, func(ctx context.Context, progress service.JobProgress) (string, error) {
+		return s.genCert(ctx, &req, order, csr, progress)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job_id": job.ID})
+}
+
+// genCert 执行完整的签发流程并保存证书，返回证书ID，证书对返回第一张证书的ID
+func (s *AcmeCertController) genCert(ctx context.Context, req *GenCertReq, order *model.AcmeOrder, csr *x509.CertificateRequest, progress service.JobProgress) (string, error) {
+	keyTypes := []certcrypto.KeyType{req.KeyType}
+	if req.Pair {
+		keyTypes = service.CertPairKeyTypes
+	}
+
+	var certs []*certificate.Resource
+	if order != nil {
+		var err error
+		certs, err = s.genManualCert(ctx, req, order, csr, keyTypes, progress)
 		if err != nil {
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("getForCSR失败: %v", err)})
-				return
-			}
+			return "", err
 		}
-	} else { //dns自动验证
-		account, err := s.acmeAccountService.GetAccount(c.Request.Context(), &service.GetAccountReq{ID: req.AccountID})
+	} else { //自动验证，证书对的第二张证书复用第一张证书已验证的授权
+		progress.Step("验证域名并签发证书")
+		variants := make([]service.ObtainVariant, 0, len(keyTypes))
+		for _, keyType := range keyTypes {
+			variants = append(variants, service.ObtainVariant{KeyType: keyType})
+		}
+		var err error
+		certs, err = s.acmeCertService.ObtainCertPair(ctx, &service.ObtainCertReq{
+			KeyType:        keyTypes[0],
+			AccountID:      req.AccountID,
+			Domains:        req.Domains,
+			Solver:         req.Solver,
+			DNSProviderID:  req.DNSProviderID,
+			DNSRoutes:      req.DNSRoutes,
+			Webroot:        req.Webroot,
+			PreferredChain: req.PreferredChain,
+			Profile:        req.Profile,
+			CSR:            csr,
+			MustStaple:     req.MustStaple,
+			OnDNSRecord:    progress.DNSRecord,
+		}, variants)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return "", err
 		}
+	}
 
-		block, _ := pem.Decode([]byte(account.KeyPem))
-		if block == nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid private key"})
-			return
+	progress.Step("保存证书")
+	var pairID string
+	if req.Pair {
+		pairID = uuid.New().String()
+	}
+	var certIDs []string
+	for i, cert := range certs {
+		// 解析证书信息
+		certInfo := service.ParseCertInfo(s.logger, string(cert.Certificate))
+
+		certID := uuid.New().String()
+		acmeCert := &model.AcmeCert{Model: model.Model{ID: certID, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+			Domains: req.Domains, Identifiers: model.NewIdentifiers(req.Domains),
+			KeyType:   keyTypes[i],
+			AccountID: req.AccountID, DNSProviderID: req.DNSProviderID, DNSRoutes: req.DNSRoutes, Solver: req.Solver, Webroot: req.Webroot,
+			PreferredChain: req.PreferredChain, Profile: req.Profile, MustStaple: req.MustStaple && csr == nil,
+			KeyPolicy: req.KeyPolicy, KeyRotateEvery: req.KeyRotateEvery, PairID: pairID,
+			CertType: certInfo.CertType, CertStatus: model.Issued,
+			IssuedAt: certInfo.IssuedAt, ValidityDays: certInfo.ValidityDays,
+			CertURL: cert.CertURL, CertStableURL: cert.CertStableURL,
+			PrivateKey: string(cert.PrivateKey), Certificate: string(cert.Certificate), IssuerCertificate: string(cert.IssuerCertificate),
+			CSR: string(cert.CSR),
 		}
-		privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		acmeCert.AutoRenew = req.Solver != model.SolverDNS01 || acmeCert.HasDNSProvider()
+		if err := s.db.Create(acmeCert).Error; err != nil {
+			return "", err
+		}
+		if _, err := s.acmeCertService.CreateCertVersion(ctx, &service.CreateCertVersionReq{CertID: certID}); err != nil {
+			return "", err
+		}
+		certIDs = append(certIDs, certID)
+	}
+
+	if order != nil {
+		err := s.acmeOrderService.UpdateOrderStatus(ctx, &service.UpdateOrderStatusReq{ID: order.ID, Status: acme.StatusValid, CertID: certIDs[0]})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Parse private key failed"})
-			return
+			s.logger.Warn("UpdateOrderStatus err: " + err.Error())
 		}
+	}
 
-		user := &service.User{
-			Email:        account.Email,
-			Registration: (*registration.Resource)(account.Registration),
-			Key:          privKey,
+	// 获取CA建议的续期窗口，失败不影响签发结果
+	for _, certID := range certIDs {
+		if err := s.acmeCertService.RefreshRenewalInfo(ctx, &service.RefreshRenewalInfoReq{ID: certID}); err != nil {
+			s.logger.Warn("RefreshRenewalInfo err: " + err.Error())
 		}
-		conf := lego.NewConfig(user)
-		conf.CADirURL = account.Server
-		conf.Certificate.KeyType = req.KeyType
-		client, err := lego.NewClient(conf)
+	}
+
+	return certIDs[0], nil
+}
+
+// genManualCert 从保存的订单URL恢复手动DNS-01订单，完成验证后为每个密钥类型签发一张证书
+func (s *AcmeCertController) genManualCert(ctx context.Context, req *GenCertReq, order *model.AcmeOrder, csr *x509.CertificateRequest,
+	keyTypes []certcrypto.KeyType, progress service.JobProgress) ([]*certificate.Resource, error) {
+	progress.Step("恢复订单")
+	core, err := s.newManualCore(ctx, order.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	acmeOrder, err := core.Orders.Get(order.OrderURL)
+	if err != nil {
+		return nil, fmt.Errorf("获取订单失败: %w", err)
+	}
+	acmeOrder.Location = order.OrderURL
+	if acmeOrder.Status != order.Status {
+		_ = s.acmeOrderService.UpdateOrderStatus(ctx, &service.UpdateOrderStatusReq{ID: order.ID, Status: acmeOrder.Status})
+	}
+	if acmeOrder.Status == acme.StatusInvalid || acmeOrder.Status == acme.StatusValid {
+		return nil, fmt.Errorf("订单状态为 %s，请重新创建授权", acmeOrder.Status)
+	}
+	var authz []acme.Authorization
+	for _, authURL := range acmeOrder.Authorizations {
+		authorization, err := core.Authorizations.Get(authURL)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Create client failed"})
-			return
+			return nil, fmt.Errorf("Failed to get auth: %w", err)
 		}
+		authz = append(authz, authorization)
+	}
 
-		dnsProverInfo, err := s.dnsService.GetDNSProver(c.Request.Context(), &service.GetDNSProviderReq{ID: req.DNSProviderID})
+	// 等待每条TXT记录在所有权威DNS服务器上生效
+	progress.Step("DNS预验证")
+	s.logger.Info("手动验证模式，开始DNS预验证")
+	for _, info := range order.Authorizations {
+		err := s.propagationService.Wait(ctx, &service.CheckPropagationReq{Domain: info.Domain, FQDN: info.EffectiveFQDN, Value: info.TXTValue},
+			progress.DNSRecord)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("get provider失败: %v", err)})
-			return
+			return nil, fmt.Errorf("DNS预验证失败: %w，请确保已正确设置 %s 的TXT记录 %s，并等待DNS传播完成",
+				err, info.EffectiveFQDN, info.TXTValue)
 		}
+	}
 
-		provider, err := s.CreateLegoDNSProvider(dnsProverInfo)
+	progress.Step("验证域名")
+	s.logger.Info("DNS预验证通过，开始正式验证")
+	for _, authorization := range authz {
+		if authorization.Status == acme.StatusValid {
+			continue
+		}
+		domain := challenge.GetTargetedDomain(authorization)
+		chall := acme.Challenge{}
+		for _, ch := range authorization.Challenges {
+			if ch.Type == string(challenge.DNS01) {
+				chall = ch
+			}
+		}
+		err := validate(ctx, core, domain, chall)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Create provider failed: %v", err)})
-			return
+			return nil, fmt.Errorf("域名 %s 验证失败: %w", domain, err)
 		}
+	}
 
-		err = client.Challenge.SetDNS01Provider(provider)
+	solversManager := resolver.NewSolversManager(core)
+	err = solversManager.SetDNS01Provider(&ManualDNSProvider{})
+	if err != nil {
+		return nil, fmt.Errorf("设置 DNS-01 ManualDNSProvider 失败: %w", err)
+	}
+	prober := resolver.NewProber(solversManager)
+	err = prober.Solve(authz)
+	if err != nil {
+		return nil, fmt.Errorf("域名 Solve失败: %w", err)
+	}
+
+	s.logger.Info(strings.Join(req.Domains, ", ") + " acme: Validations succeeded; requesting certificates")
+	var certs []*certificate.Resource
+	for i, keyType := range keyTypes {
+		progress.Step(fmt.Sprintf("签发证书(%s)", keyType))
+		if i > 0 {
+			// 一个订单只能签发一张证书，证书对的其余证书使用新订单，授权已验证无需再次设置TXT记录
+			acmeOrder, err = newReadyOrder(core, req.Domains, req.Profile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cert, err := issueForOrder(core, req, acmeOrder, csr, keyType)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "设置 DNS-01 Provider 失败: " + err.Error()})
-			return
+			return nil, err
 		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
 
-		// 申请证书
-		r := certificate.ObtainRequest{Domains: req.Domains, Bundle: true, MustStaple: false}
-		cert, err = client.Certificate.Obtain(r)
+// issueForOrder 使用用户CSR或新生成的私钥完成订单并下载证书
+func issueForOrder(core *api.Core, req *GenCertReq, order acme.ExtendedOrder, csr *x509.CertificateRequest, keyType certcrypto.KeyType) (*certificate.Resource, error) {
+	if csr != nil {
+		// 使用用户提供的CSR，不生成私钥
+		cert, err := getForCSR(core, req.Domains, order, true, csr.Raw, nil, req.PreferredChain)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return nil, fmt.Errorf("getForCSR失败: %w", err)
 		}
+		cert.CSR = certcrypto.PEMEncode(csr)
+		return cert, nil
 	}
 
-	// 解析证书信息
-	certInfo := s.parseCertInfo(string(cert.Certificate))
+	privateKey, err := certcrypto.GeneratePrivateKey(keyType)
+	if err != nil {
+		return nil, fmt.Errorf("创建私钥失败: %w", err)
+	}
 
-	err := s.db.Create(&model.AcmeCert{Model: model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		Domains:   req.Domains,
-		KeyType:   req.KeyType,
-		AccountID: req.AccountID, DNSProviderID: "cs", CertType: certInfo.CertType, CertStatus: model.Issued,
-		IssuedAt: certInfo.IssuedAt, ValidityDays: certInfo.ValidityDays,
-		CertURL: cert.CertURL, CertStableURL: cert.CertStableURL,
-		PrivateKey: string(cert.PrivateKey), Certificate: string(cert.Certificate), IssuerCertificate: string(cert.IssuerCertificate),
-		CSR: string(cert.CSR),
-	}).Error
+	csrDER, err := service.CreateCSR(privateKey, req.Domains, req.MustStaple)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, fmt.Errorf("创建CSR失败: %w", err)
+	}
+	privateKeyPem := certcrypto.PEMEncode(privateKey)
+	cert, err := getForCSR(core, req.Domains, order, true, csrDER, privateKeyPem, req.PreferredChain)
+	if err != nil {
+		return nil, fmt.Errorf("getForCSR失败: %w", err)
+	}
+	return cert, nil
+}
+
+// newReadyOrder 为已验证过授权的域名创建新订单，CA复用有效授权时订单直接处于 ready 状态
+func newReadyOrder(core *api.Core, domains []string, profile string) (acme.ExtendedOrder, error) {
+	order, err := core.Orders.NewWithOptions(domains, &api.OrderOptions{Profile: profile})
+	if err != nil {
+		return order, fmt.Errorf("Failed to create order: %w", err)
+	}
+	if order.Status != acme.StatusReady {
+		return order, fmt.Errorf("新订单状态为 %s，CA未复用已验证的授权", order.Status)
+	}
+	return order, nil
+}
+
+// GetJob 查询异步任务的状态、当前步骤和错误信息
+func (s *AcmeCertController) GetJob(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+	currentUser, _ := c.Get(common.CurrentUSer)
+	user, ok := currentUser.(*model.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
 		return
 	}
+	job, err := s.acmeJobService.GetJob(c.Request.Context(), &service.GetJobReq{ID: id, CreatedBy: user.ID})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
 
+// CancelJob 取消排队中或执行中的异步任务
+func (s *AcmeCertController) CancelJob(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+	currentUser, _ := c.Get(common.CurrentUSer)
+	user, ok := currentUser.(*model.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+	err := s.acmeJobService.CancelJob(c.Request.Context(), &service.CancelJobReq{ID: id, CreatedBy: user.ID})
+	if err != nil {
+		s.logger.Error("CancelJob err: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, nil)
 }
 
@@ -575,7 +911,7 @@ func hasPreferredChain(issuer []byte, preferredChain string) (bool, error) {
 	return false, nil
 }
 
-func validate(core *api.Core, domain string, chlg acme.Challenge) error {
+func validate(ctx context.Context, core *api.Core, domain string, chlg acme.Challenge) error {
 	chlng, err := core.Challenges.New(chlg.URL)
 	if err != nil {
 		return fmt.Errorf("failed to initiate challenge: %w", err)
@@ -627,7 +963,7 @@ func validate(core *api.Core, domain string, chlg acme.Challenge) error {
 		return fmt.Errorf("the server didn't respond to our request (status=%s)", authz.Status)
 	}
 
-	return backoff.Retry(operation, bo)
+	return backoff.Retry(operation, backoff.WithContext(bo, ctx))
 }
 
 func checkChallengeStatus(chlng acme.ExtendedChallenge) (bool, error) {
@@ -663,15 +999,33 @@ func checkAuthorizationStatus(authz acme.Authorization) (bool, error) {
 	}
 }
 
+// Value 手动验证订单的TXT记录信息
 type Value struct {
-	Id       string `json:"id"`
-	client   *lego.Client
-	core     *api.Core
-	order    acme.ExtendedOrder
-	authz    []acme.Authorization
+	Id       string                `json:"id"`
 	InfoList []dns01.ChallengeInfo `json:"info_list"`
 }
 
+func newValue(order *model.AcmeOrder) *Value {
+	val := &Value{Id: order.ID}
+	for _, authorization := range order.Authorizations {
+		val.InfoList = append(val.InfoList, dns01.ChallengeInfo{
+			FQDN:          authorization.FQDN,
+			EffectiveFQDN: authorization.EffectiveFQDN,
+			Value:         authorization.TXTValue,
+		})
+	}
+	return val
+}
+
+// parseOrderExpires 解析订单的过期时间（RFC 3339）
+func parseOrderExpires(expires string) *time.Time {
+	t, err := time.Parse(time.RFC3339, expires)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
 func getCertifierCore(certifier *certificate.Certifier) *api.Core {
 	v := reflect.ValueOf(certifier).Elem()
 	coreField := v.FieldByName("core")
@@ -680,11 +1034,10 @@ func getCertifierCore(certifier *certificate.Certifier) *api.Core {
 	return coreValue.Elem().Interface().(*api.Core)
 }
 
-func getInfos(core *api.Core, authz []acme.Authorization) ([]dns01.ChallengeInfo, error) {
-	var challengeInfo []dns01.ChallengeInfo
-	for _, authorization := range authz {
-		//domain := challenge.GetTargetedDomain(authorization)
-
+// getAuthorizations 生成每个授权的DNS-01验证信息，authzURLs 与 authz 一一对应
+func getAuthorizations(core *api.Core, authzURLs []string, authz []acme.Authorization) ([]model.AcmeAuthorization, error) {
+	var authorizations []model.AcmeAuthorization
+	for i, authorization := range authz {
 		chlng, err := challenge.FindChallenge(challenge.DNS01, authorization)
 		if err != nil {
 			return nil, err
@@ -697,9 +1050,19 @@ func getInfos(core *api.Core, authz []acme.Authorization) ([]dns01.ChallengeInfo
 		}
 
 		info := dns01.GetChallengeInfo(authorization.Identifier.Value, keyAuth)
-		challengeInfo = append(challengeInfo, info)
-	}
-	return challengeInfo, nil
+		authorizations = append(authorizations, model.AcmeAuthorization{
+			Model:         model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+			Domain:        challenge.GetTargetedDomain(authorization),
+			AuthzURL:      authzURLs[i],
+			ChallengeURL:  chlng.URL,
+			Token:         chlng.Token,
+			FQDN:          info.FQDN,
+			EffectiveFQDN: info.EffectiveFQDN,
+			TXTValue:      info.Value,
+			Status:        authorization.Status,
+		})
+	}
+	return authorizations, nil
 }
 
 func (s *AcmeCertController) DeleteAcmeCert(c *gin.Context) {
@@ -724,7 +1087,17 @@ func (s *AcmeCertController) RevokeCert(c *gin.Context) {
 		return
 	}
 
-	err := s.acmeCertService.RevokeCert(c.Request.Context(), &service.RevokeCertReq{ID: id})
+	// 请求体可省略，此时由签发账户吊销且不提交原因
+	var req service.RevokeCertReq
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.ID = id
+
+	err := s.acmeCertService.RevokeCert(c.Request.Context(), &req)
 	if err != nil {
 		s.logger.Error("RevokeCert err: " + err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -734,7 +1107,7 @@ func (s *AcmeCertController) RevokeCert(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "证书吊销成功"})
 }
 
-func (s *AcmeCertController) DownloadCertChain(c *gin.Context) {
+func (s *AcmeCertController) RenewCert(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
@@ -743,17 +1116,137 @@ func (s *AcmeCertController) DownloadCertChain(c *gin.Context) {
 
 	cert, err := s.acmeCertService.GetCert(c.Request.Context(), &service.GetCertReq{ID: id})
 	if err != nil {
-		s.logger.Error("DownloadCertChain GetCert err: " + err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if cert.IsExternal() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrExternalCert.Error()})
+		return
+	}
+
+	currentUser, _ := c.Get(common.CurrentUSer)
+	user, ok := currentUser.(*model.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+
+	// 续期包含DNS传播等待和订单轮询，与签发一样放到异步任务中执行
+	job, err := s.acmeJobService.SubmitJob(c.Request.Context(), &service.SubmitJobReq{
+		Type: model.JobTypeRenew, Domains: cert.Domains, CreatedBy: user.ID,
+	}, func(ctx context.Context, progress service.JobProgress) (string, error) {
+		progress.Step("续期证书")
+		return id, s.acmeCertService.RenewCert(ctx, &service.RenewCertReq{ID: id, OnDNSRecord: progress.DNSRecord})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job_id": job.ID})
+}
+
+// ListCertChains 列出CA为证书提供的默认链和备用链
+func (s *AcmeCertController) ListCertChains(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	chains, err := s.acmeCertService.ListCertChains(c.Request.Context(), &service.ListCertChainsReq{ID: id})
+	if err != nil {
+		s.logger.Error("ListCertChains err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, chains)
+}
+
+// SwitchCertChain 切换证书使用的证书链，续期时继续使用该链
+func (s *AcmeCertController) SwitchCertChain(c *gin.Context) {
+	var req service.SwitchCertChainReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ID = c.Param("id")
+	if req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	if err := s.acmeCertService.SwitchCertChain(c.Request.Context(), &req); err != nil {
+		s.logger.Error("SwitchCertChain err: " + err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "证书链切换成功"})
+}
+
+func (s *AcmeCertController) DownloadCertChain(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	cert, version, err := s.getActiveVersion(c, id)
+	if err != nil {
+		s.logger.Error("DownloadCertChain getActiveVersion err: " + err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_chain.pem\"", cert.Domains[0]))
 	c.Header("Content-Type", "application/x-pem-file")
-	c.String(http.StatusOK, cert.Certificate)
+	c.String(http.StatusOK, version.Certificate)
 }
 
-func (s *AcmeCertController) DownloadPrivateKey(c *gin.Context) {
+// UpdateKeyPolicy 修改证书续期时的私钥策略
+func (s *AcmeCertController) UpdateKeyPolicy(c *gin.Context) {
+	var req service.UpdateKeyPolicyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ID = c.Param("id")
+	if req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	if err := s.acmeCertService.UpdateKeyPolicy(c.Request.Context(), &req); err != nil {
+		s.logger.Error("UpdateKeyPolicy err: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, nil)
+}
+
+// UpdateDNSRoutes 修改证书按区域选择DNS提供商的路由表，续期时生效
+func (s *AcmeCertController) UpdateDNSRoutes(c *gin.Context) {
+	var req service.UpdateDNSRoutesReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ID = c.Param("id")
+	if req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	if err := s.acmeCertService.UpdateDNSRoutes(c.Request.Context(), &req); err != nil {
+		s.logger.Error("UpdateDNSRoutes err: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, nil)
+}
+
+// DownloadOCSPResponse 下载最近一次OCSP响应(DER)，可用于配置OCSP Stapling
+func (s *AcmeCertController) DownloadOCSPResponse(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
@@ -762,99 +1255,188 @@ func (s *AcmeCertController) DownloadPrivateKey(c *gin.Context) {
 
 	cert, err := s.acmeCertService.GetCert(c.Request.Context(), &service.GetCertReq{ID: id})
 	if err != nil {
-		s.logger.Error("DownloadPrivateKey GetCert err: " + err.Error())
+		s.logger.Error("DownloadOCSPResponse GetCert err: " + err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if len(cert.OCSPResponse) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "暂无OCSP响应"})
+		return
+	}
 
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_private.pem\"", cert.Domains[0]))
-	c.Header("Content-Type", "application/octet-stream")
-	c.String(http.StatusOK, cert.PrivateKey)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.ocsp\"", cert.Domains[0]))
+	c.Data(http.StatusOK, "application/ocsp-response", cert.OCSPResponse)
 }
 
-func (s *AcmeCertController) GetPrivateKey(c *gin.Context) {
+// CheckOCSP 立即查询证书的OCSP状态
+func (s *AcmeCertController) CheckOCSP(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
 		return
 	}
 
+	if err := s.acmeCertService.CheckOCSP(c.Request.Context(), &service.CheckOCSPReq{ID: id}); err != nil {
+		s.logger.Error("CheckOCSP err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	cert, err := s.acmeCertService.GetCert(c.Request.Context(), &service.GetCertReq{ID: id})
 	if err != nil {
-		s.logger.Error("GetPrivateKey GetCert err: " + err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"private_key": cert.PrivateKey})
+	c.JSON(http.StatusOK, cert)
 }
 
-// dnsPreCheck 执行DNS预验证，检查TXT记录是否已正确设置
-func (s *AcmeCertController) dnsPreCheck(fqdn, expectedValue string) error {
-	// 简化版DNS预验证，使用标准库的net包
-	s.logger.Info("开始DNS预验证", zap.String("fqdn", fqdn), zap.String("expected", expectedValue))
+// DownloadCertPair 将证书对的证书和私钥打包为zip下载，文件名以密钥类型区分
+func (s *AcmeCertController) DownloadCertPair(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
 
-	// 使用Go标准库查询TXT记录
-	txtRecords, err := net.LookupTXT(fqdn)
+	certs, err := s.acmeCertService.GetCertPair(c.Request.Context(), &service.GetCertPairReq{ID: id})
 	if err != nil {
-		return fmt.Errorf("DNS查询失败: %w", err)
+		s.logger.Error("DownloadCertPair GetCertPair err: " + err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 检查是否包含期望的值
-	for _, record := range txtRecords {
-		if record == expectedValue {
-			s.logger.Info("DNS预验证成功", zap.String("fqdn", fqdn), zap.String("value", expectedValue))
-			return nil
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, cert := range certs {
+		_, version, err := s.getActiveVersion(c, cert.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		files := map[string]string{
+			fmt.Sprintf("%s_%s.crt", cert.Domains[0], cert.KeyType): version.Certificate,
+			fmt.Sprintf("%s_%s.key", cert.Domains[0], cert.KeyType): version.PrivateKey,
 		}
+		for name, content := range files {
+			w, err := zw.Create(name)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if _, err := w.Write([]byte(content)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 如果没有找到，等待一段时间后再试一次
-	s.logger.Info("首次DNS查询未找到记录，等待30秒后重试...")
-	time.Sleep(30 * time.Second)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_pair.zip\"", certs[0].Domains[0]))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
 
-	txtRecords, err = net.LookupTXT(fqdn)
+// getActiveVersion 返回证书及其当前生效的版本，下载均使用生效版本的内容
+func (s *AcmeCertController) getActiveVersion(c *gin.Context, id string) (*model.AcmeCert, *model.AcmeCertVersion, error) {
+	cert, err := s.acmeCertService.GetCert(c.Request.Context(), &service.GetCertReq{ID: id})
+	if err != nil {
+		return nil, nil, err
+	}
+	version, err := s.acmeCertService.GetActiveVersion(c.Request.Context(), &service.GetActiveVersionReq{CertID: id})
 	if err != nil {
-		return fmt.Errorf("DNS重试查询失败: %w", err)
+		return nil, nil, err
 	}
+	return cert, version, nil
+}
 
-	for _, record := range txtRecords {
-		if record == expectedValue {
-			s.logger.Info("DNS预验证重试成功", zap.String("fqdn", fqdn), zap.String("value", expectedValue))
-			return nil
-		}
+// ListCertVersions 查询证书的签发版本历史
+func (s *AcmeCertController) ListCertVersions(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
 	}
+	versions, err := s.acmeCertService.ListCertVersions(c.Request.Context(), &service.ListCertVersionsReq{CertID: id})
+	if err != nil {
+		s.logger.Error("ListCertVersions err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
 
-	return fmt.Errorf("DNS预验证失败: 未找到期望的TXT记录值 '%s'，当前记录: %v", expectedValue, txtRecords)
+// RollbackCertVersion 将证书的生效版本切换回之前的版本
+func (s *AcmeCertController) RollbackCertVersion(c *gin.Context) {
+	var req service.RollbackCertVersionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.CertID = c.Param("id")
+	if req.CertID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	if err := s.acmeCertService.RollbackCertVersion(c.Request.Context(), &req); err != nil {
+		s.logger.Error("RollbackCertVersion err: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, nil)
 }
 
-// findAuthoritativeServers 简化版本，不使用复杂的DNS查询
-func (s *AcmeCertController) findAuthoritativeServers(fqdn string) ([]string, error) {
-	// 简化实现，返回常用的公共DNS服务器
-	return []string{"8.8.8.8:53", "1.1.1.1:53"}, nil
+// noPrivateKeyError 版本没有私钥时的提示：使用用户CSR签发的证书私钥只保存在用户侧，外部证书则是上传时未提供私钥
+func noPrivateKeyError(cert *model.AcmeCert) string {
+	if cert.IsExternal() {
+		return "该证书上传时未提供私钥，服务器未保存私钥"
+	}
+	return "该证书使用用户提供的CSR签发，服务器未保存私钥"
 }
 
-// checkTXTRecord 简化版本，使用标准库
-func (s *AcmeCertController) checkTXTRecord(fqdn, expectedValue, server string) error {
-	// 使用标准库的简化实现
-	txtRecords, err := net.LookupTXT(fqdn)
-	if err != nil {
-		return fmt.Errorf("DNS查询失败: %w", err)
+func (s *AcmeCertController) DownloadPrivateKey(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
 	}
 
-	for _, record := range txtRecords {
-		if record == expectedValue {
-			return nil
-		}
+	cert, version, err := s.getActiveVersion(c, id)
+	if err != nil {
+		s.logger.Error("DownloadPrivateKey getActiveVersion err: " + err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if version.PrivateKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": noPrivateKeyError(cert)})
+		return
 	}
 
-	return fmt.Errorf("未找到期望的TXT记录值: %s", expectedValue)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_private.pem\"", cert.Domains[0]))
+	c.Header("Content-Type", "application/octet-stream")
+	c.String(http.StatusOK, version.PrivateKey)
 }
 
-// CertInfo 证书信息结构体
-type CertInfo struct {
-	CertType     model.CertType
-	IssuedAt     *time.Time
-	ValidityDays int
+func (s *AcmeCertController) GetPrivateKey(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	cert, version, err := s.getActiveVersion(c, id)
+	if err != nil {
+		s.logger.Error("GetPrivateKey getActiveVersion err: " + err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if version.PrivateKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": noPrivateKeyError(cert)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"private_key": version.PrivateKey})
 }
 
 // determineCertType 根据证书内容判断证书类型
@@ -887,95 +1469,46 @@ func (s *AcmeCertController) determineCertType(certPEM string) model.CertType {
 	return model.CertTypeDV
 }
 
-// parseCertInfo 解析证书信息，包括类型、签发时间和有效期
-func (s *AcmeCertController) parseCertInfo(certPEM string) *CertInfo {
-	// 解析证书
-	block, _ := pem.Decode([]byte(certPEM))
-	if block == nil {
-		s.logger.Warn("无法解析证书PEM，使用默认值")
-		return &CertInfo{
-			CertType:     model.CertTypeDV,
-			IssuedAt:     nil,
-			ValidityDays: 0,
+// maxExternalCertUploadSize 上传的证书文件大小上限
+const maxExternalCertUploadSize = 1 << 20
+
+// ImportExternalCert 上传外部签发的证书，file 为 PEM 证书链或 PFX 文件，key 为可选的 PEM 私钥，password 为 PFX 密码
+func (s *AcmeCertController) ImportExternalCert(c *gin.Context) {
+	data, err := readUploadFile(c, "file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传证书文件: " + err.Error()})
+		return
+	}
+	var keyData []byte
+	if _, err := c.FormFile("key"); err == nil {
+		if keyData, err = readUploadFile(c, "key"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 	}
 
-	cert, err := x509.ParseCertificate(block.Bytes)
+	cert, err := s.acmeCertService.ImportExternalCert(c.Request.Context(), &service.ImportExternalCertReq{
+		Data: data, KeyData: keyData, Password: c.PostForm("password")})
 	if err != nil {
-		s.logger.Error("解析证书失败，使用默认值", zap.Error(err))
-		return &CertInfo{
-			CertType:     model.CertTypeDV,
-			IssuedAt:     nil,
-			ValidityDays: 0,
-		}
-	}
-
-	certType := model.CertTypeDV
-	if len(cert.Subject.Organization) > 0 || len(cert.Subject.OrganizationalUnit) > 0 {
-		certType = model.CertTypeOV
-		s.logger.Info("检测到组织信息，判断为OV证书")
-	} else {
-		s.logger.Info("未检测到组织信息，判断为DV证书")
-	}
-
-	// 获取签发时间（NotBefore）
-	issuedAt := cert.NotBefore
-
-	// 计算有效期天数
-	validityDuration := cert.NotAfter.Sub(cert.NotBefore)
-	validityDays := int(validityDuration.Hours() / 24)
-
-	s.logger.Info("解析证书信息成功",
-		zap.String("cert_type", string(certType)),
-		zap.Time("issued_at", issuedAt),
-		zap.Int("validity_days", validityDays),
-		zap.Time("expires_at", cert.NotAfter))
-
-	return &CertInfo{
-		CertType:     certType,
-		IssuedAt:     &issuedAt,
-		ValidityDays: validityDays,
-	}
-}
-
-// CreateLegoDNSProvider is a factory function that creates a DNS provider instance.
-func (s *AcmeCertController) CreateLegoDNSProvider(provider *model.DNSProvider) (challenge.Provider, error) {
-	switch provider.Type {
-	case model.DNSTypeTencentCloud:
-		cf := tencentcloud.NewDefaultConfig()
-		cf.SecretID = provider.SecretId
-		cf.SecretKey = provider.SecretKey
-		return tencentcloud.NewDNSProviderConfig(cf)
-	case model.DNSTypeAliyun:
-		cf := alidns.NewDefaultConfig()
-		cf.APIKey = provider.SecretId
-		cf.SecretKey = provider.SecretKey
-		return alidns.NewDNSProviderConfig(cf)
-	case model.DNSTypeCloudflare:
-		cf := cloudflare.NewDefaultConfig()
-		cf.AuthToken = provider.SecretKey
-		return cloudflare.NewDNSProviderConfig(cf)
-	case model.DNSTypeGoDaddy:
-		cf := godaddy.NewDefaultConfig()
-		cf.APIKey = provider.SecretId
-		cf.APISecret = provider.SecretKey
-		return godaddy.NewDNSProviderConfig(cf)
-	case model.DNSTypeBaiduCloud:
-		cf := baiducloud.NewDefaultConfig()
-		cf.AccessKeyID = provider.SecretId
-		cf.SecretAccessKey = provider.SecretKey
-		return baiducloud.NewDNSProviderConfig(cf)
-	case model.DNSTypeHuaweiCloud:
-		cf := huaweicloud.NewDefaultConfig()
-		cf.AccessKeyID = provider.SecretId
-		cf.SecretAccessKey = provider.SecretKey
-		return huaweicloud.NewDNSProviderConfig(cf)
-	case model.DNSTypeRoute53:
-		cf := route53.NewDefaultConfig()
-		cf.AccessKeyID = provider.SecretId
-		cf.SecretAccessKey = provider.SecretKey
-		return route53.NewDNSProviderConfig(cf)
-	default:
-		return nil, fmt.Errorf("unsupported DNS provider type: %s", provider.Type)
+		s.logger.Error("ImportExternalCert err: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cert)
+}
+
+func readUploadFile(c *gin.Context, name string) ([]byte, error) {
+	fileHeader, err := c.FormFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if fileHeader.Size > maxExternalCertUploadSize {
+		return nil, errors.New("文件过大")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, maxExternalCertUploadSize))
 }
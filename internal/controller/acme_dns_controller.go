@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"easyacme/internal/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// AcmeDNSController 提供与 acme-dns 一致的 /register、/update 和 /health 接口
+type AcmeDNSController struct {
+	logger         *zap.Logger
+	acmeDNSService service.AcmeDNSService
+}
+
+// NewAcmeDNSController .
+func NewAcmeDNSController(logger *zap.Logger, acmeDNSService service.AcmeDNSService) *AcmeDNSController {
+	return &AcmeDNSController{
+		logger:         logger,
+		acmeDNSService: acmeDNSService,
+	}
+}
+
+// Register 注册新的 acme-dns 账户。未开放注册时需要登录
+func (s *AcmeDNSController) Register(c *gin.Context) {
+	var req service.AcmeDNSRegisterReq
+	// acme-dns 允许不带请求体注册
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "malformed_json_payload"})
+			return
+		}
+	}
+
+	resp, err := s.acmeDNSService.Register(c.Request.Context(), &req)
+	if err != nil {
+		s.logger.Error("acme-dns Register err: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (s *AcmeDNSController) Update(c *gin.Context) {
+	var req service.AcmeDNSUpdateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "malformed_json_payload"})
+		return
+	}
+	req.Username = c.GetHeader("X-Api-User")
+	req.Password = c.GetHeader("X-Api-Key")
+	// allowfrom 校验使用的客户端IP，只在配置了可信代理时才采用 X-Forwarded-For
+	req.ClientIP = c.ClientIP()
+
+	err := s.acmeDNSService.Update(c.Request.Context(), &req)
+	if err == service.ErrAcmeDNSForbidden {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"txt": req.TXT})
+}
+
+func (s *AcmeDNSController) Health(c *gin.Context) {
+	c.Status(http.StatusOK)
+}
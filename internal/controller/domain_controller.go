@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"easyacme/internal/common"
+	"easyacme/internal/model"
+	"easyacme/internal/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type DomainController struct {
+	logger        *zap.Logger
+	domainService service.DomainService
+}
+
+// NewDomainController .
+func NewDomainController(logger *zap.Logger, domainService service.DomainService) *DomainController {
+	return &DomainController{
+		logger:        logger,
+		domainService: domainService,
+	}
+}
+
+func (s *DomainController) NewDomain(c *gin.Context) {
+	var req service.CreateDomainReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.OwnerID == 0 {
+		currentUser, _ := c.Get(common.CurrentUSer)
+		if user, ok := currentUser.(*model.User); ok {
+			req.OwnerID = user.ID
+		}
+	}
+
+	domain, err := s.domainService.CreateDomain(c.Request.Context(), &req)
+	if err != nil {
+		s.logger.Error("CreateDomain err: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, domain)
+}
+
+func (s *DomainController) GetDomains(c *gin.Context) {
+	var req service.ListDomainReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := s.domainService.GetDomains(c.Request.Context(), &req)
+	if err != nil {
+		s.logger.Error("GetDomains err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetDomain 查询受管域名及覆盖它的证书
+func (s *DomainController) GetDomain(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+	domain, err := s.domainService.GetDomain(c.Request.Context(), &service.GetDomainReq{ID: id})
+	if err != nil {
+		s.logger.Error("GetDomain err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, domain)
+}
+
+func (s *DomainController) UpdateDomain(c *gin.Context) {
+	var req service.UpdateDomainReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ID = c.Param("id")
+	if req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+
+	if err := s.domainService.UpdateDomain(c.Request.Context(), &req); err != nil {
+		s.logger.Error("UpdateDomain err: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, nil)
+}
+
+func (s *DomainController) DeleteDomain(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is empty"})
+		return
+	}
+	if err := s.domainService.DeleteDomain(c.Request.Context(), &service.DeleteDomainReq{ID: id}); err != nil {
+		s.logger.Error("DeleteDomain err: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, nil)
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"easyacme/internal/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// maxImportUploadSize 上传的数据目录压缩包大小上限
+const maxImportUploadSize = 64 << 20
+
+type ImportController struct {
+	logger        *zap.Logger
+	importService service.ImportService
+}
+
+// NewImportController .
+func NewImportController(logger *zap.Logger, importService service.ImportService) *ImportController {
+	return &ImportController{
+		logger:        logger,
+		importService: importService,
+	}
+}
+
+// Import 上传 certbot(/etc/letsencrypt)、acme.sh(~/.acme.sh) 或 lego(.lego) 数据目录的 zip/tar/tar.gz 压缩包并导入，dry_run=true 时只返回导入报告
+func (s *ImportController) Import(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传压缩包: " + err.Error()})
+		return
+	}
+	if fileHeader.Size > maxImportUploadSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "压缩包过大"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultPostForm("dry_run", "true"))
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportUploadSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := s.importService.Import(c.Request.Context(), &service.ImportReq{Data: data, DryRun: dryRun})
+	if err != nil {
+		s.logger.Error("Import err: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -20,7 +20,7 @@ func NewMiddlewareManager(cfg *config.Config, logger *zap.Logger) *MiddlewareMan
 	var h []Handler
 	h = append(h, newCorsMiddleware())
 	h = append(h, newSessionMiddleware(cfg))
-	h = append(h, newAuthMiddleware())
+	h = append(h, newAuthMiddleware(cfg))
 
 	return &MiddlewareManager{handles: h}
 }
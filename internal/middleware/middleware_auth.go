@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"easyacme/internal/common"
+	"easyacme/internal/config"
 	"easyacme/internal/model"
 	"encoding/json"
 	"github.com/gin-contrib/sessions"
@@ -20,8 +21,14 @@ var notNeedAuthPath = map[string]struct{}{
 	"/api/ping":          {},
 	"/api/init/user":     {},
 	"/api/auth/language": {},
+	// acme-dns 客户端使用 X-Api-User/X-Api-Key 认证
+	"/api/acme-dns/update": {},
+	"/api/acme-dns/health": {},
 }
 
+// acmeDNSRegisterPath 仅在开放注册时无需登录
+const acmeDNSRegisterPath = "/api/acme-dns/register"
+
 // isPublicPath 检查路径是否为公开路径
 func isNotNeedAuthPath(path string) bool {
 	_, ok := notNeedAuthPath[path]
@@ -29,10 +36,12 @@ func isNotNeedAuthPath(path string) bool {
 }
 
 // newAuthMiddleware auth处理
-func newAuthMiddleware() *authMiddleware {
+func newAuthMiddleware(cfg *config.Config) *authMiddleware {
+	openRegistration := cfg.AcmeDNS.Enabled && cfg.AcmeDNS.OpenRegistration
 	return &authMiddleware{
 		auth: func(ctx *gin.Context) {
-			if isNotNeedAuthPath(ctx.Request.URL.Path) {
+			path := ctx.Request.URL.Path
+			if isNotNeedAuthPath(path) || (openRegistration && path == acmeDNSRegisterPath) {
 				ctx.Next()
 				return
 			}
@@ -0,0 +1,34 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certRenewal)
+}
+
+var certRenewal = &common.Migration{
+	ID:           "certRenewal",
+	Dependencies: []string{"initTable"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加自动续期相关字段
+		err := tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "auto_renew" bool NOT NULL DEFAULT true,
+			ADD COLUMN IF NOT EXISTS "last_renew_at" timestamp(6),
+			ADD COLUMN IF NOT EXISTS "last_renew_error" text;
+		`).Error
+		if err != nil {
+			return err
+		}
+
+		// 历史数据中的占位值 "cs" 并不是真实的DNS提供商
+		err = tx.Exec(`UPDATE "public"."acme_certs" SET "dns_provider_id" = '' WHERE "dns_provider_id" = 'cs';`).Error
+		if err != nil {
+			return err
+		}
+		return nil
+	},
+}
@@ -0,0 +1,26 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certRenewalInfo)
+}
+
+var certRenewalInfo = &common.Migration{
+	ID:           "certRenewalInfo",
+	Dependencies: []string{"certRenewal"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加 ARI 续期窗口字段
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "ari_cert_id" text,
+			ADD COLUMN IF NOT EXISTS "renewal_window_start" timestamptz(6),
+			ADD COLUMN IF NOT EXISTS "renewal_window_end" timestamptz(6),
+			ADD COLUMN IF NOT EXISTS "renewal_explanation_url" text,
+			ADD COLUMN IF NOT EXISTS "renewal_info_retry_at" timestamptz(6);
+		`).Error
+	},
+}
@@ -0,0 +1,23 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certSolver)
+}
+
+var certSolver = &common.Migration{
+	ID:           "certSolver",
+	Dependencies: []string{"certRenewalInfo"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加验证方式字段
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "solver" text DEFAULT 'dns-01'::text,
+			ADD COLUMN IF NOT EXISTS "webroot" text;
+		`).Error
+	},
+}
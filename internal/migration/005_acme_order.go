@@ -0,0 +1,66 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, acmeOrder)
+}
+
+var acmeOrder = &common.Migration{
+	ID:           "acmeOrder",
+	Dependencies: []string{"initTable"},
+	Action: func(tx *gorm.DB) error {
+		// 创建 acme_orders 表
+		err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS "public"."acme_orders" (
+			"id" text NOT NULL,
+			"created_at" timestamptz(6),
+			"updated_at" timestamptz(6),
+			"account_id" text,
+			"key_type" text,
+			"domains" text[],
+			"order_url" text,
+			"finalize_url" text,
+			"status" text,
+			"expires_at" timestamptz(6),
+			"cert_id" text,
+			"created_by" int4,
+			CONSTRAINT "acme_orders_pkey" PRIMARY KEY ("id")
+		);
+		`).Error
+		if err != nil {
+			return err
+		}
+
+		// 创建 acme_authorizations 表
+		err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS "public"."acme_authorizations" (
+			"id" text NOT NULL,
+			"created_at" timestamptz(6),
+			"updated_at" timestamptz(6),
+			"order_id" text NOT NULL,
+			"domain" text,
+			"authz_url" text,
+			"challenge_url" text,
+			"token" text,
+			"fqdn" text,
+			"effective_fqdn" text,
+			"txt_value" text,
+			"status" text,
+			CONSTRAINT "acme_authorizations_pkey" PRIMARY KEY ("id"),
+			CONSTRAINT "fk_acme_orders_authorizations" FOREIGN KEY ("order_id") REFERENCES "public"."acme_orders" ("id") ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS "idx_acme_authorizations_order_id" ON "public"."acme_authorizations" USING btree (
+			"order_id" ASC NULLS LAST
+		);
+		`).Error
+		if err != nil {
+			return err
+		}
+		return nil
+	},
+}
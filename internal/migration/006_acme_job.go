@@ -0,0 +1,39 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, acmeJob)
+}
+
+var acmeJob = &common.Migration{
+	ID:           "acmeJob",
+	Dependencies: []string{"initTable"},
+	Action: func(tx *gorm.DB) error {
+		// 创建 acme_jobs 表
+		return tx.Exec(`
+		CREATE TABLE IF NOT EXISTS "public"."acme_jobs" (
+			"id" text NOT NULL,
+			"created_at" timestamptz(6),
+			"updated_at" timestamptz(6),
+			"type" text,
+			"state" text,
+			"step" text,
+			"domains" text[],
+			"cert_id" text,
+			"error" text,
+			"created_by" int4,
+			"started_at" timestamptz(6),
+			"finished_at" timestamptz(6),
+			CONSTRAINT "acme_jobs_pkey" PRIMARY KEY ("id")
+		);
+
+		CREATE INDEX IF NOT EXISTS "idx_acme_jobs_state" ON "public"."acme_jobs" USING btree (
+			"state" ASC NULLS LAST
+		);
+		`).Error
+	},
+}
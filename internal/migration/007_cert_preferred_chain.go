@@ -0,0 +1,22 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certPreferredChain)
+}
+
+var certPreferredChain = &common.Migration{
+	ID:           "certPreferredChain",
+	Dependencies: []string{"certSolver"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加首选证书链字段
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "preferred_chain" text;
+		`).Error
+	},
+}
@@ -0,0 +1,24 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certProfile)
+}
+
+var certProfile = &common.Migration{
+	ID:           "certProfile",
+	Dependencies: []string{"certPreferredChain", "acmeOrder"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 和 acme_orders 增加CA证书profile字段
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "profile" text;
+		ALTER TABLE "public"."acme_orders"
+			ADD COLUMN IF NOT EXISTS "profile" text;
+		`).Error
+	},
+}
@@ -0,0 +1,31 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, identifiers)
+}
+
+var identifiers = &common.Migration{
+	ID:           "identifiers",
+	Dependencies: []string{"certProfile"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 和 acme_orders 增加带类型的标识字段，已有数据均为域名
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "identifiers" jsonb;
+		ALTER TABLE "public"."acme_orders"
+			ADD COLUMN IF NOT EXISTS "identifiers" jsonb;
+
+		UPDATE "public"."acme_certs" SET "identifiers" = (
+			SELECT COALESCE(jsonb_agg(jsonb_build_object('type', 'dns', 'value', d)), '[]'::jsonb) FROM unnest("domains") AS d
+		) WHERE "identifiers" IS NULL;
+		UPDATE "public"."acme_orders" SET "identifiers" = (
+			SELECT COALESCE(jsonb_agg(jsonb_build_object('type', 'dns', 'value', d)), '[]'::jsonb) FROM unnest("domains") AS d
+		) WHERE "identifiers" IS NULL;
+		`).Error
+	},
+}
@@ -0,0 +1,26 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certOCSP)
+}
+
+var certOCSP = &common.Migration{
+	ID:           "certOCSP",
+	Dependencies: []string{"identifiers"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加 Must-Staple 选项和OCSP状态字段
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "must_staple" bool NOT NULL DEFAULT false,
+			ADD COLUMN IF NOT EXISTS "ocsp_status" text,
+			ADD COLUMN IF NOT EXISTS "ocsp_response" bytea,
+			ADD COLUMN IF NOT EXISTS "ocsp_checked_at" timestamptz(6),
+			ADD COLUMN IF NOT EXISTS "ocsp_next_update" timestamptz(6);
+		`).Error
+	},
+}
@@ -0,0 +1,24 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certKeyPolicy)
+}
+
+var certKeyPolicy = &common.Migration{
+	ID:           "certKeyPolicy",
+	Dependencies: []string{"certOCSP"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加续期私钥策略字段
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "key_policy" text DEFAULT 'rotate'::text,
+			ADD COLUMN IF NOT EXISTS "key_rotate_every" int4 NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS "key_reuse_count" int4 NOT NULL DEFAULT 0;
+		`).Error
+	},
+}
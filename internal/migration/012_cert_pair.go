@@ -0,0 +1,26 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certPair)
+}
+
+var certPair = &common.Migration{
+	ID:           "certPair",
+	Dependencies: []string{"certKeyPolicy"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加证书对标识
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "pair_id" text;
+
+		CREATE INDEX IF NOT EXISTS "idx_acme_certs_pair_id" ON "public"."acme_certs" USING btree (
+			"pair_id" ASC NULLS LAST
+		);
+		`).Error
+	},
+}
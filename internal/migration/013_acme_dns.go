@@ -0,0 +1,39 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, acmeDNS)
+}
+
+var acmeDNS = &common.Migration{
+	ID:           "acmeDNS",
+	Dependencies: []string{"initTable"},
+	Action: func(tx *gorm.DB) error {
+		// 创建内置 acme-dns 注册表
+		return tx.Exec(`
+		CREATE TABLE IF NOT EXISTS "public"."acme_dns_registrations" (
+			"id" text NOT NULL,
+			"created_at" timestamptz(6),
+			"updated_at" timestamptz(6),
+			"username" text NOT NULL,
+			"password_hash" text NOT NULL,
+			"subdomain" text NOT NULL,
+			"allow_from" text[],
+			"txt" text[],
+			"last_update_at" timestamptz(6),
+			CONSTRAINT "acme_dns_registrations_pkey" PRIMARY KEY ("id")
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS "idx_acme_dns_registrations_username" ON "public"."acme_dns_registrations" USING btree (
+			"username" ASC NULLS LAST
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS "idx_acme_dns_registrations_subdomain" ON "public"."acme_dns_registrations" USING btree (
+			"subdomain" ASC NULLS LAST
+		);
+		`).Error
+	},
+}
@@ -0,0 +1,22 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certDNSRoutes)
+}
+
+var certDNSRoutes = &common.Migration{
+	ID:           "certDNSRoutes",
+	Dependencies: []string{"certPair"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加按区域选择DNS提供商的路由表
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "dns_routes" jsonb;
+		`).Error
+	},
+}
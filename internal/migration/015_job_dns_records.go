@@ -0,0 +1,22 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, jobDNSRecords)
+}
+
+var jobDNSRecords = &common.Migration{
+	ID:           "jobDNSRecords",
+	Dependencies: []string{"acmeJob"},
+	Action: func(tx *gorm.DB) error {
+		// acme_jobs 增加DNS-01 TXT记录的传播状态
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_jobs"
+			ADD COLUMN IF NOT EXISTS "dns_records" jsonb;
+		`).Error
+	},
+}
@@ -0,0 +1,35 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, domains)
+}
+
+var domains = &common.Migration{
+	ID:           "domains",
+	Dependencies: []string{"initTable"},
+	Action: func(tx *gorm.DB) error {
+		// 创建 domains 表
+		return tx.Exec(`
+		CREATE TABLE IF NOT EXISTS "public"."domains" (
+			"id" text NOT NULL,
+			"created_at" timestamptz(6),
+			"updated_at" timestamptz(6),
+			"name" text NOT NULL,
+			"dns_provider_id" text,
+			"account_id" text,
+			"owner_id" int4,
+			"notes" text,
+			CONSTRAINT "domains_pkey" PRIMARY KEY ("id")
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS "idx_domains_name" ON "public"."domains" USING btree (
+			"name" ASC NULLS LAST
+		);
+		`).Error
+	},
+}
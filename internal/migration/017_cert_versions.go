@@ -0,0 +1,100 @@
+package migration
+
+import (
+	"crypto/x509"
+	"easyacme/internal/common"
+	"encoding/pem"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certVersions)
+}
+
+var certVersions = &common.Migration{
+	ID:           "certVersions",
+	Dependencies: []string{"certDNSRoutes"},
+	Action: func(tx *gorm.DB) error {
+		// 创建 acme_cert_versions 表，acme_certs 增加当前生效版本
+		err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS "public"."acme_cert_versions" (
+			"id" text NOT NULL,
+			"created_at" timestamptz(6),
+			"updated_at" timestamptz(6),
+			"cert_id" text NOT NULL,
+			"version" int4 NOT NULL,
+			"replaces_id" text,
+			"serial" text,
+			"status" text,
+			"key_type" text,
+			"issued_at" timestamptz(6),
+			"expires_at" timestamptz(6),
+			"validity_days" int4,
+			"cert_url" text,
+			"cert_stable_url" text,
+			"private_key" text,
+			"certificate" text,
+			"issuer_certificate" text,
+			"csr" text,
+			CONSTRAINT "acme_cert_versions_pkey" PRIMARY KEY ("id"),
+			CONSTRAINT "fk_acme_certs_versions" FOREIGN KEY ("cert_id") REFERENCES "public"."acme_certs" ("id") ON DELETE CASCADE
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS "idx_acme_cert_versions_cert_id_version" ON "public"."acme_cert_versions" USING btree (
+			"cert_id" ASC NULLS LAST,
+			"version" ASC NULLS LAST
+		);
+
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "active_version_id" text;
+		`).Error
+		if err != nil {
+			return err
+		}
+
+		// 已签发的证书作为各自的第1个版本，版本ID沿用证书ID
+		err = tx.Exec(`
+		INSERT INTO "public"."acme_cert_versions" ("id", "created_at", "updated_at", "cert_id", "version", "status", "key_type",
+			"issued_at", "expires_at", "validity_days", "cert_url", "cert_stable_url", "private_key", "certificate", "issuer_certificate", "csr")
+		SELECT "id", NOW(), NOW(), "id", 1, CASE WHEN "cert_status" = 'revoked' THEN 'revoked' ELSE 'active' END, "key_type",
+			"issued_at", "issued_at" + ("validity_days" || ' days')::interval, "validity_days", "cert_url", "cert_stable_url",
+			"private_key", "certificate", "issuer_certificate", "csr"
+		FROM "public"."acme_certs"
+		WHERE COALESCE("certificate", '') <> '' AND COALESCE("active_version_id", '') = ''
+		ON CONFLICT ("id") DO NOTHING;
+
+		UPDATE "public"."acme_certs" SET "active_version_id" = "id"
+		WHERE COALESCE("certificate", '') <> '' AND COALESCE("active_version_id", '') = '';
+		`).Error
+		if err != nil {
+			return err
+		}
+
+		// 序列号和到期时间需要解析证书得到
+		type versionRow struct {
+			ID          string
+			Certificate string
+		}
+		var rows []versionRow
+		if err := tx.Raw(`SELECT "id", "certificate" FROM "public"."acme_cert_versions" WHERE COALESCE("serial", '') = ''`).Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			block, _ := pem.Decode([]byte(row.Certificate))
+			if block == nil {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				continue
+			}
+			err = tx.Exec(`UPDATE "public"."acme_cert_versions" SET "serial" = ?, "issued_at" = ?, "expires_at" = ? WHERE "id" = ?`,
+				fmt.Sprintf("%x", cert.SerialNumber), cert.NotBefore, cert.NotAfter, row.ID).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
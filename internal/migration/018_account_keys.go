@@ -0,0 +1,85 @@
+package migration
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"easyacme/internal/common"
+	"encoding/pem"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+func init() {
+	AllMigration = append(AllMigration, accountKeys)
+}
+
+var accountKeys = &common.Migration{
+	ID:           "accountKeys",
+	Dependencies: []string{"initTable"},
+	Action: func(tx *gorm.DB) error {
+		// 创建 acme_account_keys 表，记录账户密钥轮换历史，每个账户同时只允许一条进行中的轮换
+		err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS "public"."acme_account_keys" (
+			"id" text NOT NULL,
+			"created_at" timestamptz(6),
+			"updated_at" timestamptz(6),
+			"account_id" text NOT NULL,
+			"status" text NOT NULL DEFAULT 'completed',
+			"key_type" text,
+			"key_pem" text,
+			"thumbprint" text,
+			"new_key_type" text,
+			"new_key_pem" text,
+			"new_thumbprint" text,
+			"retired_at" timestamptz(6),
+			"retired_by" int4,
+			CONSTRAINT "acme_account_keys_pkey" PRIMARY KEY ("id")
+		);
+
+		CREATE INDEX IF NOT EXISTS "idx_acme_account_keys_account_id" ON "public"."acme_account_keys" USING btree (
+			"account_id" ASC NULLS LAST
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS "idx_acme_account_keys_pending" ON "public"."acme_account_keys" ("account_id")
+			WHERE "status" = 'pending';
+		`).Error
+		if err != nil {
+			return err
+		}
+
+		// 旧版本创建账户时 key_type 固定写入 RSA2048，按保存的私钥重新计算
+		type accountRow struct {
+			ID     string
+			KeyPem string
+		}
+		var rows []accountRow
+		if err := tx.Raw(`SELECT "id", "key_pem" FROM "public"."acme_accounts"`).Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			block, _ := pem.Decode([]byte(row.KeyPem))
+			if block == nil {
+				continue
+			}
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				continue
+			}
+			var keyType string
+			switch k := key.(type) {
+			case *rsa.PrivateKey:
+				keyType = fmt.Sprintf("RSA%d", k.N.BitLen())
+			case *ecdsa.PrivateKey:
+				keyType = "EC" + strings.TrimPrefix(k.Curve.Params().Name, "P-")
+			default:
+				continue
+			}
+			if err := tx.Exec(`UPDATE "public"."acme_accounts" SET "key_type" = ? WHERE "id" = ?`, keyType, row.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
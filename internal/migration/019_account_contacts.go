@@ -0,0 +1,26 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, accountContacts)
+}
+
+var accountContacts = &common.Migration{
+	ID:           "accountContacts",
+	Dependencies: []string{"initTable"},
+	Action: func(tx *gorm.DB) error {
+		// acme_accounts 增加联系人列表和已同意的服务条款，已有账户的联系人取自 email
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_accounts"
+			ADD COLUMN IF NOT EXISTS "contacts" text[],
+			ADD COLUMN IF NOT EXISTS "terms_of_service" text;
+
+		UPDATE "public"."acme_accounts" SET "contacts" = ARRAY["email"]
+			WHERE "contacts" IS NULL AND "email" IS NOT NULL AND "email" != '';
+		`).Error
+	},
+}
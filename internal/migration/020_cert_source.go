@@ -0,0 +1,24 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certSource)
+}
+
+var certSource = &common.Migration{
+	ID:           "certSource",
+	Dependencies: []string{"initTable"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加证书来源，已有证书均为ACME签发
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "source" text DEFAULT 'acme';
+
+		UPDATE "public"."acme_certs" SET "source" = 'acme' WHERE "source" IS NULL;
+		`).Error
+	},
+}
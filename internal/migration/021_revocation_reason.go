@@ -0,0 +1,27 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, revocationReason)
+}
+
+var revocationReason = &common.Migration{
+	ID:           "revocationReason",
+	Dependencies: []string{"certVersions"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 和 acme_cert_versions 增加吊销原因和吊销时间
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "revocation_reason" text,
+			ADD COLUMN IF NOT EXISTS "revoked_at" timestamptz(6);
+
+		ALTER TABLE "public"."acme_cert_versions"
+			ADD COLUMN IF NOT EXISTS "revocation_reason" text,
+			ADD COLUMN IF NOT EXISTS "revoked_at" timestamptz(6);
+		`).Error
+	},
+}
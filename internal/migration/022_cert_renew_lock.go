@@ -0,0 +1,22 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certRenewLock)
+}
+
+var certRenewLock = &common.Migration{
+	ID:           "certRenewLock",
+	Dependencies: []string{"certRenewal"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加续期锁，避免同一证书被并发续期
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "renewing_at" timestamptz(6);
+		`).Error
+	},
+}
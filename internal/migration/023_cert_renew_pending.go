@@ -0,0 +1,22 @@
+package migration
+
+import (
+	"easyacme/internal/common"
+	"gorm.io/gorm"
+)
+
+func init() {
+	AllMigration = append(AllMigration, certRenewPending)
+}
+
+var certRenewPending = &common.Migration{
+	ID:           "certRenewPending",
+	Dependencies: []string{"certPair"},
+	Action: func(tx *gorm.DB) error {
+		// acme_certs 增加证书对待补签标记
+		return tx.Exec(`
+		ALTER TABLE "public"."acme_certs"
+			ADD COLUMN IF NOT EXISTS "renew_pending" boolean NOT NULL DEFAULT false;
+		`).Error
+	},
+}
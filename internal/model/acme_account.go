@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"github.com/go-acme/lego/v4/registration"
+	"github.com/lib/pq"
 	"time"
 )
 
@@ -15,16 +16,21 @@ type Model struct {
 
 type AcmeAccount struct {
 	Model
-	Name         string                `json:"name"`
-	KeyPem       string                `json:"key_pem"`
-	KeyType      string                `json:"key_type"`
-	Uri          string                `json:"uri"`
-	Server       string                `json:"server"`
-	Email        string                `json:"email"`
-	Status       string                `json:"status"`
-	EABKeyID     string                `json:"eab_key_id"`
-	EABMacKey    string                `json:"eab_mac_key"`
-	Registration *RegistrationResource `json:"registration" gorm:"column:registration;type:jsonb"`
+	Name           string                `json:"name"`
+	KeyPem         string                `json:"key_pem"`
+	KeyType        string                `json:"key_type"`
+	Uri            string                `json:"uri"`
+	Server         string                `json:"server"`
+	Email          string                `json:"email"`
+	Status         string                `json:"status"`
+	EABKeyID       string                `json:"eab_key_id"`
+	EABMacKey      string                `json:"eab_mac_key"`
+	Registration   *RegistrationResource `json:"registration" gorm:"column:registration;type:jsonb"`
+	Contacts       pq.StringArray        `json:"contacts" gorm:"type:text[]"` // 联系邮箱，可以为空
+	TermsOfService string                `json:"terms_of_service"`            // 账户已同意的服务条款URL
+
+	TermsChanged          bool   `json:"terms_changed" gorm:"-"`            // CA目录中的服务条款已变更，需要重新同意
+	CurrentTermsOfService string `json:"current_terms_of_service" gorm:"-"` // CA目录中当前的服务条款URL
 }
 
 type RegistrationResource registration.Resource
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+type AccountKeyStatus string
+
+const (
+	AccountKeyPending   AccountKeyStatus = "pending"   // 已生成新密钥，等待CA确认或提交
+	AccountKeyCompleted AccountKeyStatus = "completed" // 轮换完成，账户已使用新密钥
+	AccountKeyFailed    AccountKeyStatus = "failed"    // CA未接受新密钥，账户继续使用旧密钥
+)
+
+// AcmeAccountKey 账户密钥轮换记录，保存被替换下来的旧密钥以备审计
+type AcmeAccountKey struct {
+	Model
+	AccountID     string           `json:"account_id"`
+	Status        AccountKeyStatus `json:"status"`
+	KeyType       string           `json:"key_type"`
+	KeyPem        string           `json:"-"`
+	Thumbprint    string           `json:"thumbprint"`     // 旧密钥的JWK指纹(RFC 7638)
+	NewKeyType    string           `json:"new_key_type"`   // 替换后的密钥类型
+	NewKeyPem     string           `json:"-"`              // 轮换进行中时保存新密钥，用于恢复
+	NewThumbprint string           `json:"new_thumbprint"` // 替换后的密钥指纹
+	RetiredAt     time.Time        `json:"retired_at" gorm:"type:timestamptz"`
+	RetiredBy     int              `json:"retired_by"` // 执行轮换的用户
+}
+
+func (a AcmeAccountKey) TableName() string {
+	return "acme_account_keys"
+}
@@ -14,6 +14,14 @@ const (
 	CertTypeOV CertType = "OV" // Organization Validation 组织验证型证书
 )
 
+// CertSource 证书来源
+type CertSource string
+
+const (
+	CertSourceACME     CertSource = "acme"     // 通过ACME签发
+	CertSourceExternal CertSource = "external" // 上传的外部证书（商业CA、内部CA），只跟踪有效期
+)
+
 type CertStatus string
 
 const (
@@ -23,24 +31,154 @@ const (
 	Revoked   CertStatus = "revoked"
 )
 
+// RevocationReason 吊销原因，取 RFC 5280 CRLReason 的名称
+type RevocationReason string
+
+const (
+	RevocationUnspecified          RevocationReason = "unspecified"
+	RevocationKeyCompromise        RevocationReason = "keyCompromise"
+	RevocationAffiliationChanged   RevocationReason = "affiliationChanged"
+	RevocationSuperseded           RevocationReason = "superseded"
+	RevocationCessationOfOperation RevocationReason = "cessationOfOperation"
+)
+
+// Code 返回原因对应的 CRLReason 代码，订阅者不能使用的原因返回 false
+func (r RevocationReason) Code() (uint, bool) {
+	switch r {
+	case RevocationUnspecified:
+		return 0, true
+	case RevocationKeyCompromise:
+		return 1, true
+	case RevocationAffiliationChanged:
+		return 3, true
+	case RevocationSuperseded:
+		return 4, true
+	case RevocationCessationOfOperation:
+		return 5, true
+	default:
+		return 0, false
+	}
+}
+
+// RevocationReasonFromCode 将 CRLReason 代码转换为原因名称，CA使用的其他原因返回 unspecified
+func RevocationReasonFromCode(code int) RevocationReason {
+	for _, r := range []RevocationReason{RevocationKeyCompromise, RevocationAffiliationChanged,
+		RevocationSuperseded, RevocationCessationOfOperation} {
+		if c, _ := r.Code(); int(c) == code {
+			return r
+		}
+	}
+	return RevocationUnspecified
+}
+
+// KeyPolicy 续期时的私钥策略
+type KeyPolicy string
+
+const (
+	KeyPolicyRotate      KeyPolicy = "rotate"       // 每次续期都生成新私钥
+	KeyPolicyReuse       KeyPolicy = "reuse"        // 续期时复用现有私钥
+	KeyPolicyRotateEvery KeyPolicy = "rotate_every" // 每N次续期更换一次私钥
+)
+
+// IsValid 验证私钥策略是否有效
+func (p KeyPolicy) IsValid() bool {
+	switch p {
+	case KeyPolicyRotate, KeyPolicyReuse, KeyPolicyRotateEvery:
+		return true
+	default:
+		return false
+	}
+}
+
+// OCSP 状态
+const (
+	OCSPGood        = "good"
+	OCSPRevoked     = "revoked"
+	OCSPUnknown     = "unknown"
+	OCSPNoResponder = "no_responder" // 证书中没有OCSP地址，不再检查
+)
+
+// ChallengeSolver 证书域名验证方式
+type ChallengeSolver string
+
+const (
+	SolverDNS01            ChallengeSolver = "dns-01"             // DNS-01，未指定DNS提供商时为手动验证
+	SolverHTTP01Standalone ChallengeSolver = "http-01-standalone" // HTTP-01，内置监听服务响应验证请求
+	SolverHTTP01Webroot    ChallengeSolver = "http-01-webroot"    // HTTP-01，将验证文件写入webroot目录
+	SolverTLSALPN01        ChallengeSolver = "tls-alpn-01"        // TLS-ALPN-01，临时TLS监听服务响应 acme-tls/1
+)
+
+// IsValid 验证验证方式是否有效
+func (s ChallengeSolver) IsValid() bool {
+	switch s {
+	case SolverDNS01, SolverHTTP01Standalone, SolverHTTP01Webroot, SolverTLSALPN01:
+		return true
+	default:
+		return false
+	}
+}
+
 type AcmeCert struct {
 	Model
 	Domains           pq.StringArray     `json:"domains" gorm:"type:text[]"`
+	Identifiers       Identifiers        `json:"identifiers" gorm:"type:jsonb"` // 带类型的标识，值与 Domains 一一对应
 	KeyType           certcrypto.KeyType `json:"key_type"`
 	AccountID         string             `json:"account_id"`
 	DNSProviderID     string             `json:"dns_provider_id"`
+	DNSRoutes         DNSRoutes          `json:"dns_routes" gorm:"type:jsonb"` // 按区域选择DNS提供商，未匹配的域名使用 DNSProviderID
+	Solver            ChallengeSolver    `json:"solver" gorm:"type:text;default:'dns-01'"`
+	Webroot           string             `json:"webroot"`         // webroot验证方式使用的目录
+	PreferredChain    string             `json:"preferred_chain"` // 首选证书链的顶级颁发者CN，为空时使用CA默认链
+	Profile           string             `json:"profile"`         // 申请时使用的CA证书profile，为空时使用CA默认配置
+	MustStaple        bool               `json:"must_staple"`     // 证书是否包含 OCSP Must-Staple 扩展
+	KeyPolicy         KeyPolicy          `json:"key_policy" gorm:"type:text;default:'rotate'"`
+	KeyRotateEvery    int                `json:"key_rotate_every"` // rotate_every 策略下每多少次续期更换私钥
+	KeyReuseCount     int                `json:"key_reuse_count"`  // 当前私钥已被续期复用的次数
+	PairID            string             `json:"pair_id"`          // RSA/ECDSA证书对标识，同一对证书一起签发和续期
+	Source            CertSource         `json:"source" gorm:"type:text;default:'acme'"`
 	CertType          CertType           `json:"cert_type" gorm:"type:text;default:'DV'"`
 	CertStatus        CertStatus         `json:"cert_status" gorm:"type:text;default:'not_issued'"`
-	IssuedAt          *time.Time         `json:"issued_at" gorm:"type:timestamp"`   // 签发时间
-	ValidityDays      int                `json:"validity_days" gorm:"type:integer"` // 有效期（天数）
+	RevocationReason  RevocationReason   `json:"revocation_reason"`                  // 吊销原因，未吊销时为空
+	RevokedAt         *time.Time         `json:"revoked_at" gorm:"type:timestamptz"` // 吊销时间
+	IssuedAt          *time.Time         `json:"issued_at" gorm:"type:timestamp"`    // 签发时间
+	ValidityDays      int                `json:"validity_days" gorm:"type:integer"`  // 有效期（天数）
+	ActiveVersionID   string             `json:"active_version_id"`                  // 当前生效的签发版本，下载使用该版本；以下证书内容字段为该版本的副本
 	CertURL           string             `json:"cert_url"`
 	CertStableURL     string             `json:"cert_stable_url"`
 	PrivateKey        string             `json:"private_key"` //todo encrypt
 	Certificate       string             `json:"certificate"`
 	IssuerCertificate string             `json:"issuer_certificate"`
 	CSR               string             `json:"csr"`
+	AutoRenew         bool               `json:"auto_renew"`                          // 是否自动续期
+	LastRenewAt       *time.Time         `json:"last_renew_at" gorm:"type:timestamp"` // 最近一次续期尝试时间
+	LastRenewError    string             `json:"last_renew_error"`                    // 最近一次续期失败原因
+	RenewPending      bool               `json:"renew_pending"`                       // 证书对续期时未能签发的一半，下次续期只补签这张证书
+	RenewingAt        *time.Time         `json:"renewing_at" gorm:"type:timestamptz"` // 正在续期的开始时间，用作续期锁，续期结束后清空
+
+	// ARI (RFC 9773) CA建议的续期窗口
+	ARICertID             string     `json:"ari_cert_id"`                                   // ARI证书标识，续期时作为 replaces 提交
+	RenewalWindowStart    *time.Time `json:"renewal_window_start" gorm:"type:timestamptz"`  // 建议续期窗口开始时间
+	RenewalWindowEnd      *time.Time `json:"renewal_window_end" gorm:"type:timestamptz"`    // 建议续期窗口结束时间
+	RenewalExplanationURL string     `json:"renewal_explanation_url"`                       // CA对续期窗口的说明链接
+	RenewalInfoRetryAt    *time.Time `json:"renewal_info_retry_at" gorm:"type:timestamptz"` // 下次查询续期信息的时间
+
+	// OCSP 状态检查
+	OCSPStatus     string     `json:"ocsp_status"`                              // 最近一次OCSP查询结果
+	OCSPResponse   []byte     `json:"-" gorm:"type:bytea"`                      // 最近一次OCSP响应(DER)，用于OCSP Stapling
+	OCSPCheckedAt  *time.Time `json:"ocsp_checked_at" gorm:"type:timestamptz"`  // 最近一次OCSP查询时间
+	OCSPNextUpdate *time.Time `json:"ocsp_next_update" gorm:"type:timestamptz"` // OCSP响应的下次更新时间
 }
 
 func (a AcmeCert) TableName() string {
 	return "acme_certs"
 }
+
+// IsExternal 是否为外部证书，外部证书没有ACME账户，不能续期和吊销
+func (a AcmeCert) IsExternal() bool {
+	return a.Source == CertSourceExternal
+}
+
+// HasDNSProvider DNS-01证书是否配置了DNS提供商，未配置的为手动验证
+func (a AcmeCert) HasDNSProvider() bool {
+	return a.DNSProviderID != "" || len(a.DNSRoutes) > 0
+}
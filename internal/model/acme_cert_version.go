@@ -0,0 +1,41 @@
+package model
+
+import (
+	"github.com/go-acme/lego/v4/certcrypto"
+	"time"
+)
+
+// CertVersionStatus 签发版本状态
+type CertVersionStatus string
+
+const (
+	CertVersionActive     CertVersionStatus = "active"     // 当前生效的版本
+	CertVersionSuperseded CertVersionStatus = "superseded" // 已被续期或回滚替换
+	CertVersionRevoked    CertVersionStatus = "revoked"    // 已吊销，不能再回滚到该版本
+)
+
+// AcmeCertVersion 证书定义的一次签发结果，续期和重新签发都会产生新版本
+type AcmeCertVersion struct {
+	Model
+	CertID            string             `json:"cert_id"`
+	Version           int                `json:"version"`     // 从1开始递增
+	ReplacesID        string             `json:"replaces_id"` // 续期前的版本，构成续期链
+	Serial            string             `json:"serial"`      // 证书序列号(十六进制)
+	Status            CertVersionStatus  `json:"status"`
+	RevocationReason  RevocationReason   `json:"revocation_reason"`
+	RevokedAt         *time.Time         `json:"revoked_at" gorm:"type:timestamptz"`
+	KeyType           certcrypto.KeyType `json:"key_type"`
+	IssuedAt          *time.Time         `json:"issued_at" gorm:"type:timestamptz"`  // NotBefore
+	ExpiresAt         *time.Time         `json:"expires_at" gorm:"type:timestamptz"` // NotAfter
+	ValidityDays      int                `json:"validity_days"`
+	CertURL           string             `json:"cert_url"`
+	CertStableURL     string             `json:"cert_stable_url"`
+	PrivateKey        string             `json:"-"`
+	Certificate       string             `json:"certificate"`
+	IssuerCertificate string             `json:"issuer_certificate"`
+	CSR               string             `json:"csr"`
+}
+
+func (a AcmeCertVersion) TableName() string {
+	return "acme_cert_versions"
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+// AcmeDNSRegistration acme-dns 兼容的注册信息，每个注册对应内置DNS服务下的一个子域名，
+// 用户将 _acme-challenge.[domain] CNAME 到该子域名后即可自动完成DNS-01验证
+type AcmeDNSRegistration struct {
+	Model
+	Username     string         `json:"username"`
+	PasswordHash string         `json:"-"`
+	Subdomain    string         `json:"subdomain"`
+	AllowFrom    pq.StringArray `json:"allowfrom" gorm:"type:text[]"` // 允许调用更新接口的来源网段，为空时不限制
+	TXT          pq.StringArray `json:"txt" gorm:"type:text[]"`       // 最近两次设置的TXT值，便于同时验证 example.com 和 *.example.com
+	LastUpdateAt *time.Time     `json:"last_update_at" gorm:"type:timestamptz"`
+}
+
+func (a AcmeDNSRegistration) TableName() string {
+	return "acme_dns_registrations"
+}
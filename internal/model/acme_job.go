@@ -0,0 +1,44 @@
+package model
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+// JobState 异步任务状态
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// JobType 异步任务类型
+type JobType string
+
+const (
+	JobTypeIssue JobType = "issue" // 签发证书
+	JobTypeRenew JobType = "renew" // 手动续期证书
+)
+
+// AcmeJob 异步执行的ACME任务，记录执行状态和当前步骤供前端轮询
+type AcmeJob struct {
+	Model
+	Type       JobType           `json:"type"`
+	State      JobState          `json:"state"`
+	Step       string            `json:"step"`                          // 当前执行步骤
+	DNSRecords DNSRecordStatuses `json:"dns_records" gorm:"type:jsonb"` // DNS-01 TXT记录的传播状态
+	Domains    pq.StringArray    `json:"domains" gorm:"type:text[]"`
+	CertID     string            `json:"cert_id"` // 任务成功后生成的证书
+	Error      string            `json:"error"`
+	CreatedBy  int               `json:"created_by"`
+	StartedAt  *time.Time        `json:"started_at" gorm:"type:timestamptz"`
+	FinishedAt *time.Time        `json:"finished_at" gorm:"type:timestamptz"`
+}
+
+func (a AcmeJob) TableName() string {
+	return "acme_jobs"
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/lib/pq"
+	"time"
+)
+
+// AcmeOrder 手动DNS-01验证的ACME订单，保存订单URL以便在服务重启后继续完成签发
+type AcmeOrder struct {
+	Model
+	AccountID   string             `json:"account_id"`
+	KeyType     certcrypto.KeyType `json:"key_type"`
+	Domains     pq.StringArray     `json:"domains" gorm:"type:text[]"`
+	Identifiers Identifiers        `json:"identifiers" gorm:"type:jsonb"`
+	Profile     string             `json:"profile"` // 创建订单时指定的CA证书profile
+	OrderURL    string             `json:"order_url"`
+	FinalizeURL string             `json:"finalize_url"`
+	Status      string             `json:"status"`                             // CA返回的订单状态: pending, ready, processing, valid, invalid
+	ExpiresAt   *time.Time         `json:"expires_at" gorm:"type:timestamptz"` // CA侧订单过期时间
+	CertID      string             `json:"cert_id"`                            // 签发成功后对应的证书
+	CreatedBy   int                `json:"created_by"`                         // 创建订单的用户
+
+	Authorizations []AcmeAuthorization `json:"authorizations,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+func (a AcmeOrder) TableName() string {
+	return "acme_orders"
+}
+
+// AcmeAuthorization 订单中单个标识的授权及其DNS-01验证信息
+type AcmeAuthorization struct {
+	Model
+	OrderID       string `json:"order_id"`
+	Domain        string `json:"domain"`
+	AuthzURL      string `json:"authz_url"`
+	ChallengeURL  string `json:"challenge_url"`
+	Token         string `json:"token"`
+	FQDN          string `json:"fqdn"`           // _acme-challenge.[domain].
+	EffectiveFQDN string `json:"effective_fqdn"` // 经CNAME解析后实际需要设置TXT记录的域名
+	TXTValue      string `json:"txt_value"`
+	Status        string `json:"status"`
+}
+
+func (a AcmeAuthorization) TableName() string {
+	return "acme_authorizations"
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// NameserverStatus 单个权威DNS服务器上的TXT记录查询结果
+type NameserverStatus struct {
+	Nameserver string   `json:"nameserver"`
+	Found      bool     `json:"found"`  // 是否查询到期望的值
+	Values     []string `json:"values"` // 查询到的全部TXT值
+	Error      string   `json:"error,omitempty"`
+}
+
+// DNSRecordStatus DNS-01 TXT记录在各权威DNS服务器上的传播状态
+type DNSRecordStatus struct {
+	Domain      string             `json:"domain"`
+	FQDN        string             `json:"fqdn"`
+	Value       string             `json:"value"`
+	Zone        string             `json:"zone"`
+	Propagated  bool               `json:"propagated"` // 所有权威DNS服务器都已返回期望的值
+	Nameservers []NameserverStatus `json:"nameservers"`
+	Error       string             `json:"error,omitempty"`
+	CheckedAt   time.Time          `json:"checked_at"`
+}
+
+// DNSRecordStatuses 记录传播状态列表，以jsonb保存
+type DNSRecordStatuses []DNSRecordStatus
+
+// Upsert 按 FQDN 和值更新或追加一条记录状态
+func (records DNSRecordStatuses) Upsert(status DNSRecordStatus) DNSRecordStatuses {
+	for i := range records {
+		if records[i].FQDN == status.FQDN && records[i].Value == status.Value {
+			records[i] = status
+			return records
+		}
+	}
+	return append(records, status)
+}
+
+func (records DNSRecordStatuses) Value() (driver.Value, error) {
+	if records == nil {
+		return nil, nil
+	}
+	return json.Marshal(records)
+}
+
+func (records *DNSRecordStatuses) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, records)
+}
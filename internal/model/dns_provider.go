@@ -12,12 +12,13 @@ const (
 	DNSTypeCloudflare   DNSType = "cloudflare"
 	DNSTypeGoDaddy      DNSType = "godaddy"
 	DNSTypeRoute53      DNSType = "route53"
+	DNSTypeAcmeDNS      DNSType = "acmedns" // 内置 acme-dns 兼容DNS服务，凭据为注册返回的用户名和密码
 )
 
 // IsValid 验证DNS类型是否有效
 func (d DNSType) IsValid() bool {
 	switch d {
-	case DNSTypeTencentCloud, DNSTypeAliyun, DNSTypeHuaweiCloud, DNSTypeBaiduCloud, DNSTypeCloudflare, DNSTypeGoDaddy, DNSTypeRoute53:
+	case DNSTypeTencentCloud, DNSTypeAliyun, DNSTypeHuaweiCloud, DNSTypeBaiduCloud, DNSTypeCloudflare, DNSTypeGoDaddy, DNSTypeRoute53, DNSTypeAcmeDNS:
 		return true
 	default:
 		return false
@@ -46,6 +47,8 @@ func (d DNSType) GetDisplayName() string {
 		return "GoDaddy"
 	case DNSTypeRoute53:
 		return "AWS Route53"
+	case DNSTypeAcmeDNS:
+		return "内置 acme-dns"
 	default:
 		return "未知"
 	}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+)
+
+// DNSRoute 将区域或单个域名映射到DNS提供商。Zone 为 "a.com" 时匹配 a.com 及其所有子域名
+type DNSRoute struct {
+	Zone          string `json:"zone"`
+	DNSProviderID string `json:"dns_provider_id"`
+}
+
+// DNSRoutes 证书的DNS提供商路由表，以jsonb保存
+type DNSRoutes []DNSRoute
+
+// Match 返回与域名匹配的最长区域对应的路由，通配符域名按其基础域名匹配
+func (routes DNSRoutes) Match(domain string) (DNSRoute, bool) {
+	domain = strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(domain), "*."), ".")
+	var best DNSRoute
+	found := false
+	for _, route := range routes {
+		zone := strings.TrimSuffix(strings.ToLower(route.Zone), ".")
+		if domain != zone && !strings.HasSuffix(domain, "."+zone) {
+			continue
+		}
+		if !found || len(zone) > len(strings.TrimSuffix(best.Zone, ".")) {
+			best, found = route, true
+		}
+	}
+	return best, found
+}
+
+func (routes DNSRoutes) Value() (driver.Value, error) {
+	if routes == nil {
+		return nil, nil
+	}
+	return json.Marshal(routes)
+}
+
+func (routes *DNSRoutes) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, routes)
+}
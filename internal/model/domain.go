@@ -0,0 +1,38 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Domain 受管域名，可以是区域（如 example.com，覆盖其所有子域名）或单个域名，签发时据此补全账户和DNS提供商
+type Domain struct {
+	Model
+	Name          string `json:"name"`            // 小写，不含末尾的点
+	DNSProviderID string `json:"dns_provider_id"` // 默认DNS提供商
+	AccountID     string `json:"account_id"`      // 默认ACME账户
+	OwnerID       int    `json:"owner_id"`        // 负责人
+	Notes         string `json:"notes"`
+
+	Certificates []DomainCert `json:"certificates,omitempty" gorm:"-"` // 覆盖该域名的证书，查询时计算
+}
+
+func (d Domain) TableName() string {
+	return "domains"
+}
+
+// DomainCert 覆盖受管域名的证书摘要
+type DomainCert struct {
+	ID           string     `json:"id"`
+	Domains      []string   `json:"domains"`
+	KeyType      string     `json:"key_type"`
+	CertStatus   CertStatus `json:"cert_status"`
+	IssuedAt     *time.Time `json:"issued_at"`
+	ValidityDays int        `json:"validity_days"`
+	AutoRenew    bool       `json:"auto_renew"`
+}
+
+// NormalizeDomainName 统一域名格式：小写并去掉末尾的点
+func NormalizeDomainName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
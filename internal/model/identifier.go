@@ -0,0 +1,74 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net"
+)
+
+// IdentifierType ACME标识类型
+type IdentifierType string
+
+const (
+	IdentifierDNS IdentifierType = "dns" // 域名
+	IdentifierIP  IdentifierType = "ip"  // IP地址 (RFC 8738)
+)
+
+// Identifier 证书申请的标识
+type Identifier struct {
+	Type  IdentifierType `json:"type"`
+	Value string         `json:"value"`
+}
+
+// Identifiers 标识列表，以jsonb保存
+type Identifiers []Identifier
+
+// NewIdentifiers 根据值推断标识类型，可解析为IP的为ip标识，其余为dns标识
+func NewIdentifiers(values []string) Identifiers {
+	identifiers := make(Identifiers, 0, len(values))
+	for _, value := range values {
+		identifier := Identifier{Type: IdentifierDNS, Value: value}
+		if net.ParseIP(value) != nil {
+			identifier.Type = IdentifierIP
+		}
+		identifiers = append(identifiers, identifier)
+	}
+	return identifiers
+}
+
+// Values 返回所有标识的值
+func (ids Identifiers) Values() []string {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id.Value)
+	}
+	return values
+}
+
+// HasIP 是否包含IP标识
+func (ids Identifiers) HasIP() bool {
+	for _, id := range ids {
+		if id.Type == IdentifierIP {
+			return true
+		}
+	}
+	return false
+}
+
+func (ids Identifiers) Value() (driver.Value, error) {
+	if ids == nil {
+		return nil, nil
+	}
+	return json.Marshal(ids)
+}
+
+func (ids *Identifiers) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, ids)
+}
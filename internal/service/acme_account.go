@@ -8,15 +8,21 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"easyacme/internal/config"
 	"easyacme/internal/model"
 	"encoding/pem"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/lego"
 	"github.com/go-acme/lego/v4/registration"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -47,18 +53,26 @@ type AcmeAccountService interface {
 	DeleteAcmeAccount(ctx context.Context, req *DeleteAcmeAccountReq) error
 	DeactivateAcmeAccount(ctx context.Context, req *DeactivateAcmeAccountReq) error
 	GetAccountStats(ctx context.Context) (*AccountStats, error)
+	GetProfiles(ctx context.Context, req *GetProfilesReq) ([]Profile, error)
+	RolloverKey(ctx context.Context, req *RolloverKeyReq) error
+	ListAccountKeys(ctx context.Context, req *ListAccountKeysReq) ([]model.AcmeAccountKey, error)
+	UpdateAccountContacts(ctx context.Context, req *UpdateAccountContactsReq) error
+	AgreeTermsOfService(ctx context.Context, req *AgreeTermsOfServiceReq) error
+	ImportAcmeAccount(ctx context.Context, req *ImportAcmeAccountReq) (*model.AcmeAccount, error)
 }
 
 type AcmeAccountServiceImpl struct {
 	db     *gorm.DB
 	logger *zap.Logger
+	cache  config.Cache
 }
 
 // NewAcmeAccountService .
-func NewAcmeAccountService(db *gorm.DB, logger *zap.Logger) AcmeAccountService {
+func NewAcmeAccountService(db *gorm.DB, logger *zap.Logger, cache config.Cache) AcmeAccountService {
 	return &AcmeAccountServiceImpl{
 		db:     db,
 		logger: logger,
+		cache:  cache,
 	}
 }
 
@@ -100,9 +114,12 @@ func (s *AcmeAccountServiceImpl) CreateAcmeAccount(ctx context.Context, req *Cre
 
 	id := uuid.New().String()
 
-	account := model.AcmeAccount{Model: model.Model{ID: id, CreatedAt: time.Now(), UpdatedAt: time.Now()}, Name: req.Name, KeyPem: string(pemStr), KeyType: "RSA2048",
+	account := model.AcmeAccount{Model: model.Model{ID: id, CreatedAt: time.Now(), UpdatedAt: time.Now()}, Name: req.Name, KeyPem: string(pemStr), KeyType: accountKeyTypeName(req.KeyType),
 		Uri: result.URI, Email: req.Email, Server: conf.CADirURL, Status: result.Body.Status,
-		Registration: (*model.RegistrationResource)(result)}
+		Registration: (*model.RegistrationResource)(result), TermsOfService: client.GetToSURL()}
+	if req.Email != "" {
+		account.Contacts = []string{req.Email}
+	}
 	if req.EABKID != "" && req.EABHMACKey != "" {
 		account.EABKeyID = req.EABKID
 		account.EABMacKey = req.EABHMACKey
@@ -172,6 +189,7 @@ func (s *AcmeAccountServiceImpl) GetAccounts(ctx context.Context, req *ListAccou
 	if err := query.Offset(offset).Limit(req.PageSize).Order("created_at desc").Find(&accounts).Error; err != nil {
 		return nil, errors.Wrap(err, "failure to query account")
 	}
+	s.fillTermsOfService(ctx, accounts)
 	resp.List = accounts
 	return resp, nil
 }
@@ -203,6 +221,110 @@ func (u *User) GetRegistration() *registration.Resource {
 func (u *User) GetPrivateKey() crypto.PrivateKey {
 	return u.Key
 }
+
+// Profile CA在目录元数据中公布的证书配置（如 shortlived、tlsserver）
+type Profile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type GetProfilesReq struct {
+	ID string
+}
+
+// GetProfiles 从账户所在CA的目录元数据中读取可用的证书profile
+func (a *AcmeAccountServiceImpl) GetProfiles(ctx context.Context, req *GetProfilesReq) ([]Profile, error) {
+	account, err := a.GetAccount(ctx, &GetAccountReq{ID: req.ID})
+	if err != nil {
+		return nil, err
+	}
+	core, err := NewLegoCore(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]Profile, 0, len(core.GetDirectory().Meta.Profiles))
+	for name, description := range core.GetDirectory().Meta.Profiles {
+		profiles = append(profiles, Profile{Name: name, Description: description})
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles, nil
+}
+
+// contextTransport 将ACME请求绑定到指定context，context取消时中断进行中的请求
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// NewLegoClient 使用ACME账户的密钥和注册信息创建lego客户端，客户端发出的ACME请求受 ctx 控制
+func NewLegoClient(ctx context.Context, account *model.AcmeAccount, keyType certcrypto.KeyType) (*lego.Client, error) {
+	conf, err := newLegoConfig(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	if keyType != "" {
+		conf.Certificate.KeyType = keyType
+	}
+	client, err := lego.NewClient(conf)
+	if err != nil {
+		return nil, errors.Wrap(err, "Create client failed")
+	}
+	return client, nil
+}
+
+// NewLegoCore 创建底层ACME API客户端，用于lego.Client未提供的操作（如获取备用证书链）
+func NewLegoCore(ctx context.Context, account *model.AcmeAccount) (*api.Core, error) {
+	conf, err := newLegoConfig(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	var kid string
+	if account.Registration != nil {
+		kid = account.Registration.URI
+	}
+	core, err := api.New(conf.HTTPClient, conf.UserAgent, conf.CADirURL, kid, conf.User.GetPrivateKey())
+	if err != nil {
+		return nil, errors.Wrap(err, "Create core failed")
+	}
+	return core, nil
+}
+
+// AccountURI 返回账户在CA的URL，即CAA accounturi 参数的取值
+func AccountURI(account *model.AcmeAccount) string {
+	if account.Registration != nil && account.Registration.URI != "" {
+		return account.Registration.URI
+	}
+	return account.Uri
+}
+
+func newLegoConfig(ctx context.Context, account *model.AcmeAccount) (*lego.Config, error) {
+	block, _ := pem.Decode([]byte(account.KeyPem))
+	if block == nil {
+		return nil, errors.New("Invalid private key")
+	}
+	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("Parse private key failed")
+	}
+
+	user := &User{
+		Email:        account.Email,
+		Registration: (*registration.Resource)(account.Registration),
+		Key:          privKey,
+	}
+	conf := lego.NewConfig(user)
+	conf.CADirURL = account.Server
+	conf.HTTPClient.Transport = &contextTransport{ctx: ctx, base: conf.HTTPClient.Transport}
+	return conf, nil
+}
+
 func newUser(req *CreateAcmeAccountReq) (*User, error) {
 
 	var err error
@@ -215,6 +337,8 @@ func newUser(req *CreateAcmeAccountReq) (*User, error) {
 	case "2048", "3072", "4096", "8192":
 		val, _ := strconv.Atoi(req.KeyType)
 		privateKey, err = rsa.GenerateKey(rand.Reader, val)
+	default:
+		return nil, errors.Errorf("不支持的密钥类型: %s", req.KeyType)
 	}
 	if err != nil {
 		return nil, err
@@ -226,6 +350,14 @@ func newUser(req *CreateAcmeAccountReq) (*User, error) {
 	}, nil
 }
 
+// accountKeyTypeName 账户 key_type 列的写法(RSA2048、EC256)，keyType 为创建账户时的取值(2048、P256)
+func accountKeyTypeName(keyType string) string {
+	if curve, ok := strings.CutPrefix(keyType, "P"); ok {
+		return "EC" + curve
+	}
+	return "RSA" + keyType
+}
+
 type DeleteAcmeAccountReq struct {
 	ID string
 }
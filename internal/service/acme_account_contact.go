@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"easyacme/internal/model"
+	"encoding/json"
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	termsOfServiceCacheTTL = time.Hour
+	termsOfServiceErrorTTL = 5 * time.Minute // 获取失败的结果也缓存，CA不可达时列表请求不会每次都等待
+	termsOfServiceTimeout  = 5 * time.Second
+)
+
+type UpdateAccountContactsReq struct {
+	ID     string
+	Emails []string `json:"emails"`
+}
+
+// UpdateAccountContacts 更新账户联系人并同步到CA，emails 为空时清空联系人
+func (s *AcmeAccountServiceImpl) UpdateAccountContacts(ctx context.Context, req *UpdateAccountContactsReq) error {
+	emails, err := normalizeContactEmails(req.Emails)
+	if err != nil {
+		return err
+	}
+	account, err := s.GetAccount(ctx, &GetAccountReq{ID: req.ID})
+	if err != nil {
+		return err
+	}
+	if account.Status != "valid" {
+		return errors.Errorf("账户状态为 %s，不能更新联系人", account.Status)
+	}
+
+	// contact 不能省略，否则CA不会清空已有联系人
+	contacts := make([]string, 0, len(emails))
+	for _, email := range emails {
+		contacts = append(contacts, "mailto:"+email)
+	}
+	reg, _, err := updateRegistration(ctx, account, struct {
+		Contact []string `json:"contact"`
+	}{Contact: contacts})
+	if err != nil {
+		return errors.Wrap(err, "failure to update acme registration")
+	}
+
+	var email string
+	if len(emails) > 0 {
+		email = emails[0]
+	}
+	updates := map[string]interface{}{
+		"contacts":     pq.StringArray(emails),
+		"email":        email,
+		"registration": reg,
+		"updated_at":   time.Now(),
+	}
+	if reg.Body.Status != "" {
+		updates["status"] = reg.Body.Status
+	}
+	if err := s.db.Model(&model.AcmeAccount{}).Where("id = ?", account.ID).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "failure to update account contacts")
+	}
+	return nil
+}
+
+type AgreeTermsOfServiceReq struct {
+	ID string
+}
+
+// AgreeTermsOfService 同意CA目录中当前的服务条款
+func (s *AcmeAccountServiceImpl) AgreeTermsOfService(ctx context.Context, req *AgreeTermsOfServiceReq) error {
+	account, err := s.GetAccount(ctx, &GetAccountReq{ID: req.ID})
+	if err != nil {
+		return err
+	}
+	if account.Status != "valid" {
+		return errors.Errorf("账户状态为 %s，不能同意服务条款", account.Status)
+	}
+
+	reg, directory, err := updateRegistration(ctx, account, struct {
+		TermsOfServiceAgreed bool `json:"termsOfServiceAgreed"`
+	}{TermsOfServiceAgreed: true})
+	if err != nil {
+		return errors.Wrap(err, "failure to agree terms of service")
+	}
+
+	updates := map[string]interface{}{
+		"terms_of_service": directory.Meta.TermsOfService,
+		"registration":     reg,
+		"updated_at":       time.Now(),
+	}
+	if reg.Body.Status != "" {
+		updates["status"] = reg.Body.Status
+	}
+	if err := s.db.Model(&model.AcmeAccount{}).Where("id = ?", account.ID).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "failure to update account terms of service")
+	}
+	s.cache.Set(termsOfServiceCacheKey(account.Server), directory.Meta.TermsOfService, termsOfServiceCacheTTL)
+	return nil
+}
+
+// fillTermsOfService 对比CA目录中当前的服务条款，标记需要重新同意的账户。未记录已同意条款的旧账户不做标记
+func (s *AcmeAccountServiceImpl) fillTermsOfService(ctx context.Context, accounts []model.AcmeAccount) {
+	for i := range accounts {
+		account := &accounts[i]
+		if account.Status != "valid" {
+			continue
+		}
+		current, err := s.currentTermsOfService(ctx, account)
+		if err != nil {
+			s.logger.Warn("failed to get terms of service", zap.String("server", account.Server), zap.Error(err))
+			continue
+		}
+		account.CurrentTermsOfService = current
+		account.TermsChanged = account.TermsOfService != "" && current != "" && current != account.TermsOfService
+	}
+}
+
+// currentTermsOfService 读取CA目录中的服务条款URL，按CA缓存，失败结果缓存较短时间
+func (s *AcmeAccountServiceImpl) currentTermsOfService(ctx context.Context, account *model.AcmeAccount) (string, error) {
+	key := termsOfServiceCacheKey(account.Server)
+	if val, ok := s.cache.Get(key); ok {
+		if err, ok := val.(error); ok {
+			return "", err
+		}
+		return val.(string), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, termsOfServiceTimeout)
+	defer cancel()
+	core, err := NewLegoCore(ctx, account)
+	if err != nil {
+		s.cache.Set(key, err, termsOfServiceErrorTTL)
+		return "", err
+	}
+	tos := core.GetDirectory().Meta.TermsOfService
+	s.cache.Set(key, tos, termsOfServiceCacheTTL)
+	return tos, nil
+}
+
+func termsOfServiceCacheKey(server string) string {
+	return "acme:tos:" + server
+}
+
+// updateRegistration 向账户URL提交更新，返回CA响应的注册信息和目录
+func updateRegistration(ctx context.Context, account *model.AcmeAccount, payload interface{}) (*model.RegistrationResource, acme.Directory, error) {
+	kid := AccountURI(account)
+	if kid == "" {
+		return nil, acme.Directory{}, errors.New("账户缺少注册URL")
+	}
+	conf, err := newLegoConfig(ctx, account)
+	if err != nil {
+		return nil, acme.Directory{}, err
+	}
+	core, err := api.New(conf.HTTPClient, conf.UserAgent, conf.CADirURL, kid, conf.User.GetPrivateKey())
+	if err != nil {
+		return nil, acme.Directory{}, errors.Wrap(err, "Create core failed")
+	}
+	directory := core.GetDirectory()
+
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return nil, directory, err
+	}
+	body, err := signedPost(ctx, conf, directory, conf.User.GetPrivateKey(), kid, kid, content)
+	if err != nil {
+		return nil, directory, err
+	}
+	var acc acme.Account
+	if err := json.Unmarshal(body, &acc); err != nil {
+		return nil, directory, errors.Wrap(err, "failure to parse account")
+	}
+	return &model.RegistrationResource{URI: kid, Body: acc}, directory, nil
+}
+
+// normalizeContactEmails 校验并去重联系邮箱
+func normalizeContactEmails(emails []string) ([]string, error) {
+	result := make([]string, 0, len(emails))
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Address != email {
+			return nil, errors.Errorf("无效的邮箱: %s", email)
+		}
+		if !slices.ContainsFunc(result, func(existing string) bool { return strings.EqualFold(existing, email) }) {
+			result = append(result, email)
+		}
+	}
+	return result, nil
+}
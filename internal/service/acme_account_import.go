@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"easyacme/internal/model"
+	"encoding/pem"
+	"github.com/go-acme/lego/v4/lego"
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ImportAcmeAccountReq struct {
+	Name   string `json:"name" binding:"required"`
+	Server string `json:"server" binding:"required"`
+	Key    string `json:"key" binding:"required"` // PEM 或 JWK 格式的账户私钥
+}
+
+// ImportAcmeAccount 使用已有账户私钥在CA查找账户（onlyReturnExisting），不会注册新账户
+func (s *AcmeAccountServiceImpl) ImportAcmeAccount(ctx context.Context, req *ImportAcmeAccountReq) (*model.AcmeAccount, error) {
+	key, err := parseImportedAccountKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	keyType, err := accountKeyType(key)
+	if err != nil {
+		return nil, err
+	}
+	keyPem, err := encodeAccountKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := lego.NewConfig(&User{Key: key})
+	conf.CADirURL = req.Server
+	conf.HTTPClient.Transport = &contextTransport{ctx: ctx, base: conf.HTTPClient.Transport}
+	client, err := lego.NewClient(conf)
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to create client")
+	}
+	result, err := client.Registration.ResolveAccountByKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to resolve acme account by key")
+	}
+
+	var count int64
+	if err := s.db.Model(&model.AcmeAccount{}).Where("uri = ?", result.URI).Count(&count).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to count account")
+	}
+	if count > 0 {
+		return nil, errors.Errorf("账户已存在: %s", result.URI)
+	}
+
+	contacts := make([]string, 0, len(result.Body.Contact))
+	for _, contact := range result.Body.Contact {
+		if email, ok := strings.CutPrefix(contact, "mailto:"); ok {
+			contacts = append(contacts, email)
+		}
+	}
+	var email string
+	if len(contacts) > 0 {
+		email = contacts[0]
+	}
+
+	// 无法得知账户注册时同意的条款版本，以当前条款作为基准
+	account := &model.AcmeAccount{Model: model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		Name: req.Name, KeyPem: keyPem, KeyType: accountKeyTypeName(keyType), Uri: result.URI, Server: req.Server, Email: email,
+		Status: result.Body.Status, Registration: (*model.RegistrationResource)(result), Contacts: contacts,
+		TermsOfService: client.GetToSURL()}
+	if err := s.db.Create(account).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to create account")
+	}
+	return account, nil
+}
+
+// parseImportedAccountKey 解析 PEM（PKCS#8、PKCS#1、SEC 1）或 JWK（certbot 的 private_key.json）格式的私钥
+func parseImportedAccountKey(data string) (crypto.PrivateKey, error) {
+	data = strings.TrimSpace(data)
+	if strings.HasPrefix(data, "{") {
+		var jwk jose.JSONWebKey
+		if err := jwk.UnmarshalJSON([]byte(data)); err != nil {
+			return nil, errors.Wrap(err, "无效的JWK私钥")
+		}
+		if jwk.IsPublic() {
+			return nil, errors.New("JWK中不包含私钥")
+		}
+		return jwk.Key, nil
+	}
+
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("无效的PEM私钥")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		// 本系统导出的账户私钥以 EC PRIVATE KEY 包装 PKCS#8，SEC 1 解析失败时按 PKCS#8 解析
+		if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+			return key, nil
+		}
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法解析私钥")
+	}
+	return key, nil
+}
+
+// accountKeyType 返回密钥类型(P256、P384 或 RSA 位数)，与创建账户表单和 certcrypto.KeyType 的取值一致
+func accountKeyType(key crypto.PrivateKey) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return strconv.Itoa(k.N.BitLen()), nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return "P256", nil
+		case elliptic.P384():
+			return "P384", nil
+		}
+	}
+	return "", errors.New("不支持的账户密钥类型，仅支持RSA、P-256和P-384")
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"easyacme/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/lego"
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"time"
+)
+
+type RolloverKeyReq struct {
+	ID        string
+	KeyType   string `json:"key_type" binding:"required"`
+	RetiredBy int
+}
+
+// rolloverTimeout 向CA发起keyChange的超时时间，超过后进行中的轮换记录才允许被恢复流程接管
+const rolloverTimeout = 2 * time.Minute
+
+const accountDoesNotExistErr = "urn:ietf:params:acme:error:accountDoesNotExist"
+
+// RolloverKey 生成新的账户密钥并向CA发起密钥轮换(RFC 8555 §7.3.5)。新密钥先写入进行中的轮换记录，
+// CA确认后再替换账户密钥；请求失败或替换失败时用新密钥向CA查询账户，确认结果后补齐
+func (s *AcmeAccountServiceImpl) RolloverKey(ctx context.Context, req *RolloverKeyReq) error {
+	account, err := s.GetAccount(ctx, &GetAccountReq{ID: req.ID})
+	if err != nil {
+		return err
+	}
+	// 上次轮换未完成时先确认结果，账户密钥可能已在CA更换
+	if err := s.recoverRollover(ctx, account); err != nil {
+		return err
+	}
+	if account, err = s.GetAccount(ctx, &GetAccountReq{ID: req.ID}); err != nil {
+		return err
+	}
+	if account.Status != "valid" {
+		return errors.Errorf("账户状态为 %s，不能轮换密钥", account.Status)
+	}
+	if AccountURI(account) == "" {
+		return errors.New("账户缺少注册URL，不能轮换密钥")
+	}
+
+	user, err := newUser(&CreateAcmeAccountReq{KeyType: req.KeyType})
+	if err != nil {
+		return err
+	}
+	newPem, err := encodeAccountKey(user.Key)
+	if err != nil {
+		return err
+	}
+	caCtx, cancel := context.WithTimeout(ctx, rolloverTimeout)
+	defer cancel()
+	conf, err := newLegoConfig(caCtx, account)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	history := &model.AcmeAccountKey{Model: model.Model{ID: uuid.New().String(), CreatedAt: now, UpdatedAt: now},
+		AccountID: account.ID, Status: model.AccountKeyPending, KeyType: account.KeyType, KeyPem: account.KeyPem,
+		Thumbprint: keyThumbprint(conf.User.GetPrivateKey()), NewKeyType: accountKeyTypeName(req.KeyType), NewKeyPem: newPem,
+		NewThumbprint: keyThumbprint(user.Key), RetiredAt: now, RetiredBy: req.RetiredBy}
+	// 每个账户只能有一条进行中的轮换(部分唯一索引)，并发请求在这里失败
+	if err := s.db.Create(history).Error; err != nil {
+		return errors.Wrap(err, "failure to create account key history")
+	}
+
+	if err := changeAccountKey(caCtx, conf, account, user.Key); err != nil {
+		// 请求失败不代表CA未处理(如响应超时)，用新密钥查询确认
+		completed, rerr := s.resolveRollover(ctx, account, history)
+		if rerr != nil {
+			s.logger.Error("failed to resolve account key rollover", zap.String("account_id", account.ID), zap.Error(rerr))
+		}
+		if !completed {
+			return errors.Wrap(err, "failure to change account key")
+		}
+	} else if err := s.completeRollover(history); err != nil {
+		// CA已使用新密钥，记录保持进行中，下次轮换时由恢复流程补齐
+		return errors.Wrap(err, "CA已更换账户密钥，但保存失败，请稍后重新发起轮换以恢复")
+	}
+	s.logger.Info("account key rolled over", zap.String("account_id", account.ID),
+		zap.String("old_thumbprint", history.Thumbprint), zap.String("new_thumbprint", history.NewThumbprint))
+	return nil
+}
+
+// recoverRollover 处理账户上次未完成的轮换，正在向CA请求中的轮换不处理
+func (s *AcmeAccountServiceImpl) recoverRollover(ctx context.Context, account *model.AcmeAccount) error {
+	var history model.AcmeAccountKey
+	err := s.db.Where("account_id = ? AND status = ?", account.ID, model.AccountKeyPending).Limit(1).Find(&history).Error
+	if err != nil {
+		return errors.Wrap(err, "failure to query account key history")
+	}
+	if history.ID == "" {
+		return nil
+	}
+	if time.Since(history.CreatedAt) < rolloverTimeout {
+		return errors.New("账户密钥轮换进行中")
+	}
+	if _, err := s.resolveRollover(ctx, account, &history); err != nil {
+		return errors.Wrap(err, "上次密钥轮换未完成")
+	}
+	return nil
+}
+
+// resolveRollover 用进行中记录的新密钥向CA查询账户：能查到说明CA已接受新密钥，提交替换；账户不存在则标记失败
+func (s *AcmeAccountServiceImpl) resolveRollover(ctx context.Context, account *model.AcmeAccount, history *model.AcmeAccountKey) (bool, error) {
+	pending := *account
+	pending.KeyPem = history.NewKeyPem
+	conf, err := newLegoConfig(ctx, &pending)
+	if err != nil {
+		return false, err
+	}
+	client, err := lego.NewClient(conf)
+	if err != nil {
+		return false, errors.Wrap(err, "failure to create client")
+	}
+	result, err := client.Registration.ResolveAccountByKey()
+	var problem *acme.ProblemDetails
+	if errors.As(err, &problem) && problem.Type == accountDoesNotExistErr {
+		return false, s.updateRolloverStatus(s.db, history, model.AccountKeyFailed)
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failure to resolve acme account by key")
+	}
+	if result.URI != AccountURI(account) {
+		return false, errors.Errorf("新密钥对应的账户与当前账户不一致: %s", result.URI)
+	}
+	if err := s.completeRollover(history); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// completeRollover 替换账户密钥并结束轮换记录
+func (s *AcmeAccountServiceImpl) completeRollover(history *model.AcmeAccountKey) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.updateRolloverStatus(tx, history, model.AccountKeyCompleted); err != nil {
+			return err
+		}
+		err := tx.Model(&model.AcmeAccount{}).Where("id = ?", history.AccountID).
+			Updates(map[string]interface{}{"key_pem": history.NewKeyPem, "key_type": history.NewKeyType, "updated_at": time.Now()}).Error
+		if err != nil {
+			return errors.Wrap(err, "failure to update account key")
+		}
+		return nil
+	})
+}
+
+// updateRolloverStatus 结束进行中的轮换记录，新密钥已写入账户或不再使用，不再保留副本
+func (s *AcmeAccountServiceImpl) updateRolloverStatus(tx *gorm.DB, history *model.AcmeAccountKey, status model.AccountKeyStatus) error {
+	now := time.Now()
+	updates := map[string]interface{}{"status": status, "new_key_pem": "", "updated_at": now}
+	if status == model.AccountKeyCompleted {
+		updates["retired_at"] = now
+	}
+	result := tx.Model(&model.AcmeAccountKey{}).Where("id = ? AND status = ?", history.ID, model.AccountKeyPending).Updates(updates)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "failure to update account key history")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("密钥轮换记录已结束")
+	}
+	return nil
+}
+
+type ListAccountKeysReq struct {
+	ID string
+}
+
+// ListAccountKeys 查询账户的密钥轮换记录，最近的在前
+func (s *AcmeAccountServiceImpl) ListAccountKeys(ctx context.Context, req *ListAccountKeysReq) ([]model.AcmeAccountKey, error) {
+	var keys []model.AcmeAccountKey
+	if err := s.db.Where("account_id = ?", req.ID).Order("retired_at desc").Find(&keys).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to query account keys")
+	}
+	return keys, nil
+}
+
+// changeAccountKey 发送keyChange请求：内层JWS由新密钥签名并内嵌新公钥，外层JWS由旧密钥以账户URL签名
+func changeAccountKey(ctx context.Context, conf *lego.Config, account *model.AcmeAccount, newKey crypto.PrivateKey) error {
+	oldKey := conf.User.GetPrivateKey()
+	kid := AccountURI(account)
+	core, err := api.New(conf.HTTPClient, conf.UserAgent, conf.CADirURL, kid, oldKey)
+	if err != nil {
+		return errors.Wrap(err, "Create core failed")
+	}
+	directory := core.GetDirectory()
+	if directory.KeyChangeURL == "" {
+		return errors.New("CA不支持账户密钥轮换")
+	}
+
+	payload, err := json.Marshal(struct {
+		Account string          `json:"account"`
+		OldKey  jose.JSONWebKey `json:"oldKey"`
+	}{Account: kid, OldKey: jose.JSONWebKey{Key: oldKey.(crypto.Signer).Public()}})
+	if err != nil {
+		return err
+	}
+	inner, err := signJWS(newKey, "", directory.KeyChangeURL, payload, nil)
+	if err != nil {
+		return errors.Wrap(err, "failure to sign inner jws")
+	}
+
+	if _, err := signedPost(ctx, conf, directory, oldKey, kid, directory.KeyChangeURL, []byte(inner.FullSerialize())); err != nil {
+		return err
+	}
+	return nil
+}
+
+func encodeAccountKey(key crypto.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", errors.Wrap(err, "failure to marshal private key")
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// keyThumbprint 密钥公钥部分的JWK指纹
+func keyThumbprint(key crypto.PrivateKey) string {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return ""
+	}
+	thumbprint, err := (&jose.JSONWebKey{Key: signer.Public()}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint)
+}
@@ -1,15 +1,40 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/x509"
+	"easyacme/internal/config"
 	"easyacme/internal/model"
+	"encoding/base64"
 	"encoding/pem"
+	"fmt"
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
 	"github.com/go-acme/lego/v4/lego"
-	"github.com/go-acme/lego/v4/registration"
+	"github.com/go-acme/lego/v4/providers/http/webroot"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/ocsp"
 	"gorm.io/gorm"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type MonthlyIssuedCert struct {
@@ -22,6 +47,7 @@ type CertStats struct {
 	Valid         int64               `json:"valid"`
 	Expired       int64               `json:"expired"`
 	Revoked       int64               `json:"revoked"`
+	External      int64               `json:"external"` // 其中外部证书的数量
 	MonthlyIssued []MonthlyIssuedCert `json:"monthlyIssued"`
 }
 
@@ -31,20 +57,47 @@ type AcmeCertService interface {
 	DeleteAcmeCert(ctx context.Context, req *DeleteAcmeCertReq) error
 	RevokeCert(ctx context.Context, req *RevokeCertReq) error
 	GetCertStats(ctx context.Context) (*CertStats, error)
+	ObtainCert(ctx context.Context, req *ObtainCertReq) (*certificate.Resource, error)
+	ObtainCertPair(ctx context.Context, req *ObtainCertReq, variants []ObtainVariant) ([]*certificate.Resource, error)
+	RenewCert(ctx context.Context, req *RenewCertReq) error
+	GetCertsDueForRenewal(ctx context.Context, renewBeforeDays int) ([]model.AcmeCert, error)
+	RefreshRenewalInfo(ctx context.Context, req *RefreshRenewalInfoReq) error
+	CheckOCSP(ctx context.Context, req *CheckOCSPReq) error
+	UpdateKeyPolicy(ctx context.Context, req *UpdateKeyPolicyReq) error
+	UpdateDNSRoutes(ctx context.Context, req *UpdateDNSRoutesReq) error
+	CheckCAA(ctx context.Context, req *CheckCAAReq) error
+	GetCertPair(ctx context.Context, req *GetCertPairReq) ([]model.AcmeCert, error)
+	GetCertsForOCSPCheck(ctx context.Context) ([]model.AcmeCert, error)
+	GetCertsDueForRenewalInfo(ctx context.Context) ([]model.AcmeCert, error)
+	ListCertChains(ctx context.Context, req *ListCertChainsReq) ([]CertChain, error)
+	SwitchCertChain(ctx context.Context, req *SwitchCertChainReq) error
+	CreateCertVersion(ctx context.Context, req *CreateCertVersionReq) (*model.AcmeCertVersion, error)
+	ListCertVersions(ctx context.Context, req *ListCertVersionsReq) ([]model.AcmeCertVersion, error)
+	GetActiveVersion(ctx context.Context, req *GetActiveVersionReq) (*model.AcmeCertVersion, error)
+	RollbackCertVersion(ctx context.Context, req *RollbackCertVersionReq) error
+	ResolveWebroot(path string) (string, error)
+	ImportExternalCert(ctx context.Context, req *ImportExternalCertReq) (*model.AcmeCert, error)
 }
 
 type AcmeCertServiceImpl struct {
 	db                 *gorm.DB
 	logger             *zap.Logger
+	conf               *config.Config
 	acmeAccountService AcmeAccountService
+	dnsService         DNSService
+	propagationService PropagationService
 }
 
 // NewAcmeCertService .
-func NewAcmeCertService(db *gorm.DB, logger *zap.Logger, acmeAccountService AcmeAccountService) AcmeCertService {
+func NewAcmeCertService(db *gorm.DB, logger *zap.Logger, conf *config.Config, acmeAccountService AcmeAccountService, dnsService DNSService,
+	propagationService PropagationService) AcmeCertService {
 	return &AcmeCertServiceImpl{
 		db:                 db,
 		logger:             logger,
+		conf:               conf,
 		acmeAccountService: acmeAccountService,
+		dnsService:         dnsService,
+		propagationService: propagationService,
 	}
 }
 
@@ -54,6 +107,7 @@ type ListCertReq struct {
 	Domains    string `form:"domains"`
 	CertType   string `form:"cert_type"`
 	CertStatus string `form:"cert_status"`
+	Source     string `form:"source"`
 }
 type ListCertResp struct {
 	Total int64            `json:"total"`
@@ -78,6 +132,10 @@ func (s *AcmeCertServiceImpl) GetCerts(ctx context.Context, req *ListCertReq) (*
 		query = query.Where("cert_status = ?", req.CertStatus)
 	}
 
+	if req.Source != "" {
+		query = query.Where("source = ?", req.Source)
+	}
+
 	if err := query.Count(&total).Error; err != nil {
 		return nil, errors.Wrap(err, "failure to count cert")
 	}
@@ -116,10 +174,23 @@ func (c *AcmeCertServiceImpl) DeleteAcmeCert(ctx context.Context, req *DeleteAcm
 }
 
 type RevokeCertReq struct {
-	ID string
+	ID         string
+	Reason     model.RevocationReason `json:"reason"`       // 为空时不向CA提交原因
+	UseCertKey bool                   `json:"use_cert_key"` // 使用证书私钥签名吊销请求，不依赖签发账户
+	Server     string                 `json:"server"`       // 使用证书私钥吊销时的CA目录地址，须为已有账户所在的CA，为空时使用签发账户的CA
 }
 
+// RevokeCert 吊销证书。默认由签发账户吊销；签发账户已删除或停用、或证书从其他工具导入时，可使用证书私钥吊销(RFC 8555 §7.6)
 func (s *AcmeCertServiceImpl) RevokeCert(ctx context.Context, req *RevokeCertReq) error {
+	var reason *uint
+	if req.Reason != "" {
+		code, ok := req.Reason.Code()
+		if !ok {
+			return errors.Errorf("不支持的吊销原因: %s", req.Reason)
+		}
+		reason = &code
+	}
+
 	// 获取证书信息以便进行ACME吊销操作
 	cert, err := s.GetCert(ctx, &GetCertReq{ID: req.ID})
 	if err != nil {
@@ -127,59 +198,139 @@ func (s *AcmeCertServiceImpl) RevokeCert(ctx context.Context, req *RevokeCertReq
 		return errors.Wrap(err, "获取证书信息失败")
 	}
 
-	// 检查证书状态
+	// 检查证书状态，已过期的证书仍可吊销，是否接受由CA决定
 	if cert.CertStatus == model.Revoked {
 		return errors.New("证书已被吊销")
 	}
 
-	if cert.CertStatus != model.Issued {
+	if cert.CertStatus != model.Issued && cert.CertStatus != model.Expired {
 		return errors.New("只能吊销已签发的证书")
 	}
 
-	// 获取ACME账户信息
-	account, err := s.acmeAccountService.GetAccount(ctx, &GetAccountReq{ID: cert.AccountID})
+	// 外部证书没有签发账户，只能在上传了私钥时使用证书私钥吊销
+	if req.UseCertKey {
+		err = s.revokeWithCertKey(ctx, cert, req.Server, reason)
+	} else if cert.IsExternal() {
+		return ErrExternalCert
+	} else {
+		err = s.revokeWithAccount(ctx, cert, reason)
+	}
 	if err != nil {
-		s.logger.Error("GetAccount for revoke err: " + err.Error())
-		return errors.Wrap(err, "获取账户信息失败")
+		s.logger.Error("ACME revoke err: " + err.Error())
+		return errors.Wrap(err, "ACME吊销失败")
 	}
 
-	// 还原私钥
-	block, _ := pem.Decode([]byte(account.KeyPem))
-	if block == nil {
-		return errors.New("Invalid private key")
+	// 更新数据库中的证书状态
+	now := time.Now()
+	err = s.db.Model(&model.AcmeCert{}).Where("id = ?", req.ID).
+		Updates(map[string]interface{}{"cert_status": model.Revoked, "revocation_reason": req.Reason, "revoked_at": now}).Error
+	if err != nil {
+		return errors.Wrap(err, "failure to revoke cert")
+	}
+	if cert.ActiveVersionID != "" {
+		err := s.db.Model(&model.AcmeCertVersion{}).Where("id = ?", cert.ActiveVersionID).
+			Updates(map[string]interface{}{"status": model.CertVersionRevoked, "revocation_reason": req.Reason, "revoked_at": now}).Error
+		if err != nil {
+			return errors.Wrap(err, "failure to revoke cert version")
+		}
 	}
-	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+	s.logger.Info("Certificate revoked successfully", zap.String("cert_id", req.ID),
+		zap.String("reason", string(req.Reason)), zap.Bool("cert_key", req.UseCertKey))
+	return nil
+}
+
+// revokeWithAccount 使用签发证书的账户吊销
+func (s *AcmeCertServiceImpl) revokeWithAccount(ctx context.Context, cert *model.AcmeCert, reason *uint) error {
+	if cert.AccountID == "" {
+		return errors.New("证书没有关联的ACME账户，请使用证书私钥吊销")
+	}
+	account, err := s.acmeAccountService.GetAccount(ctx, &GetAccountReq{ID: cert.AccountID})
 	if err != nil {
-		return errors.New("Parse private key failed")
+		s.logger.Error("GetAccount for revoke err: " + err.Error())
+		return errors.Wrap(err, "获取账户信息失败，可使用证书私钥吊销")
+	}
+	if account.Status != "valid" {
+		return errors.Errorf("账户状态为 %s，请使用证书私钥吊销", account.Status)
 	}
 
-	// 创建ACME客户端
-	user := &User{
-		Email:        account.Email,
-		Registration: (*registration.Resource)(account.Registration),
-		Key:          privKey,
+	conf, err := newLegoConfig(ctx, account)
+	if err != nil {
+		return err
 	}
-	conf := lego.NewConfig(user)
-	conf.CADirURL = account.Server
 	client, err := lego.NewClient(conf)
 	if err != nil {
-		s.logger.Error("Create client for revoke err: " + err.Error())
 		return errors.Wrap(err, "Create client failed")
 	}
+	return client.Certificate.RevokeWithReason([]byte(cert.Certificate), reason)
+}
 
-	// 执行ACME证书吊销
-	err = client.Certificate.Revoke([]byte(cert.Certificate))
+// revokeWithCertKey 使用证书私钥签名吊销请求，JWS内嵌证书公钥，不需要ACME账户
+func (s *AcmeCertServiceImpl) revokeWithCertKey(ctx context.Context, cert *model.AcmeCert, server string, reason *uint) error {
+	if cert.PrivateKey == "" {
+		return errors.New("证书没有保存私钥，不能使用证书私钥吊销")
+	}
+	if server == "" && cert.AccountID != "" {
+		if account, err := s.acmeAccountService.GetAccount(ctx, &GetAccountReq{ID: cert.AccountID}); err == nil {
+			server = account.Server
+		}
+	}
+	if server == "" {
+		return errors.New("无法确定签发证书的CA，请指定CA目录地址")
+	}
+
+	privateKey, err := certcrypto.ParsePEMPrivateKey([]byte(cert.PrivateKey))
 	if err != nil {
-		s.logger.Error("ACME revoke err: " + err.Error())
-		return errors.Wrap(err, "ACME吊销失败")
+		return errors.Wrap(err, "无法解析证书私钥")
+	}
+	leaf, err := certcrypto.ParsePEMCertificate([]byte(cert.Certificate))
+	if err != nil {
+		return errors.Wrap(err, "无法解析证书")
+	}
+	if err := s.checkIssuedByServer(cert, leaf, server); err != nil {
+		return err
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return errors.New("不支持的私钥类型")
+	}
+	if pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(leaf.PublicKey) {
+		return errors.New("私钥与证书不匹配")
 	}
 
-	// 更新数据库中的证书状态
-	if err := s.db.Model(&model.AcmeCert{}).Where("id = ?", req.ID).Update("cert_status", model.Revoked).Error; err != nil {
-		return errors.Wrap(err, "failure to revoke cert")
+	conf := lego.NewConfig(&User{Key: privateKey})
+	conf.CADirURL = server
+	conf.HTTPClient.Transport = &contextTransport{ctx: ctx, base: conf.HTTPClient.Transport}
+	core, err := api.New(conf.HTTPClient, conf.UserAgent, conf.CADirURL, "", privateKey)
+	if err != nil {
+		return errors.Wrap(err, "Create core failed")
+	}
+	return core.Certificates.Revoke(acme.RevokeCertMessage{
+		Certificate: base64.RawURLEncoding.EncodeToString(leaf.Raw),
+		Reason:      reason,
+	})
+}
+
+// checkIssuedByServer 只向已知的CA(配置中的CA或已有账户所在的CA)发送吊销请求，避免请求任意地址；
+// 并确认证书由保存的颁发者证书签名，颁发者证书不完整的证书不允许使用证书私钥吊销
+func (s *AcmeCertServiceImpl) checkIssuedByServer(cert *model.AcmeCert, leaf *x509.Certificate, server string) error {
+	if !slices.Contains(s.conf.Acme.Servers, server) {
+		var count int64
+		if err := s.db.Model(&model.AcmeAccount{}).Where("server = ?", server).Count(&count).Error; err != nil {
+			return errors.Wrap(err, "failure to count account")
+		}
+		if count == 0 {
+			return errors.Errorf("未知的CA目录地址: %s", server)
+		}
 	}
 
-	s.logger.Info("Certificate revoked successfully", zap.String("cert_id", req.ID))
+	issuers, err := certcrypto.ParsePEMBundle([]byte(cert.IssuerCertificate))
+	if err != nil || len(issuers) == 0 {
+		return errors.New("证书缺少颁发者证书，无法确认签发CA")
+	}
+	if !bytes.Equal(leaf.RawIssuer, issuers[0].RawSubject) || leaf.CheckSignatureFrom(issuers[0]) != nil {
+		return errors.Errorf("证书与保存的颁发者证书不匹配: %s", leaf.Issuer.CommonName)
+	}
 	return nil
 }
 
@@ -192,12 +343,13 @@ func (s *AcmeCertServiceImpl) GetCertStats(ctx context.Context) (*CertStats, err
 			COUNT(*) AS total,
 			COUNT(*) FILTER (WHERE cert_status = 'issued' AND issued_at IS NOT NULL AND issued_at + (validity_days || ' days')::interval > NOW()) AS valid,
 			COUNT(*) FILTER (WHERE cert_status = 'issued' AND issued_at IS NOT NULL AND issued_at + (validity_days || ' days')::interval <= NOW()) AS expired,
-			COUNT(*) FILTER (WHERE cert_status = 'revoked') AS revoked
+			COUNT(*) FILTER (WHERE cert_status = 'revoked') AS revoked,
+			COUNT(*) FILTER (WHERE source = 'external') AS external
 		FROM acme_certs`
 
 	// Use Row().Scan() to map columns to fields directly, avoiding complex GORM struct mapping.
 	row := s.db.Raw(countsQuery).Row()
-	if err := row.Scan(&stats.Total, &stats.Valid, &stats.Expired, &stats.Revoked); err != nil {
+	if err := row.Scan(&stats.Total, &stats.Valid, &stats.Expired, &stats.Revoked, &stats.External); err != nil {
 		return nil, errors.Wrap(err, "failed to get certificate stats counts")
 	}
 
@@ -216,3 +368,1112 @@ func (s *AcmeCertServiceImpl) GetCertStats(ctx context.Context) (*CertStats, err
 
 	return &stats, nil
 }
+
+type ObtainCertReq struct {
+	KeyType       certcrypto.KeyType
+	AccountID     string
+	Domains       []string
+	Solver        model.ChallengeSolver
+	DNSProviderID string
+	// DNSRoutes 按区域选择DNS提供商，未匹配的域名使用 DNSProviderID
+	DNSRoutes model.DNSRoutes
+	Webroot   string
+	// CSR 用户提供的证书请求，不为空时使用该CSR申请证书，服务器不生成也不保存私钥
+	CSR *x509.CertificateRequest
+	// PrivateKey 复用的私钥，为空时生成新私钥
+	PrivateKey crypto.PrivateKey
+	// MustStaple 是否在证书中包含 OCSP Must-Staple 扩展，使用用户CSR时由CSR决定
+	MustStaple bool
+	// PreferredChain 首选证书链的顶级颁发者CN，CA提供多条链时据此选择
+	PreferredChain string
+	// Profile CA证书profile，为空时使用CA默认配置
+	Profile string
+	// ReplacesCertID 被替换证书的ARI标识，CA据此将新旧证书关联
+	ReplacesCertID string
+	// OnDNSRecord DNS-01 等待TXT记录生效期间上报每条记录的传播状态
+	OnDNSRecord func(status *model.DNSRecordStatus)
+}
+
+// ErrExternalCert 外部证书不是通过ACME签发，不支持续期、吊销等ACME操作
+var ErrExternalCert = errors.New("外部证书不支持ACME操作")
+
+// ErrIPRequiresHTTPOrALPN IP标识无法通过DNS-01验证 (RFC 8738)
+var ErrIPRequiresHTTPOrALPN = errors.New("IP标识只能使用 HTTP-01 或 TLS-ALPN-01 验证")
+
+// ObtainCert 使用指定的验证方式自动完成域名验证并申请证书
+func (s *AcmeCertServiceImpl) ObtainCert(ctx context.Context, req *ObtainCertReq) (*certificate.Resource, error) {
+	client, release, err := s.prepareObtain(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return s.obtain(client, req)
+}
+
+// ObtainVariant 证书对中每张证书各自的参数
+type ObtainVariant struct {
+	KeyType        certcrypto.KeyType
+	PrivateKey     crypto.PrivateKey // 复用的私钥，为空时按 KeyType 生成
+	ReplacesCertID string
+}
+
+// ObtainCertPair 为同一组域名依次签发多张不同密钥类型的证书。CAA检查和验证器只设置一次，
+// 后续证书复用第一张已验证的授权，失败时立即重试一次。返回按顺序成功签发的证书，遇到失败即停止
+func (s *AcmeCertServiceImpl) ObtainCertPair(ctx context.Context, req *ObtainCertReq, variants []ObtainVariant) ([]*certificate.Resource, error) {
+	client, release, err := s.prepareObtain(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var results []*certificate.Resource
+	for i, variant := range variants {
+		r := *req
+		r.KeyType, r.PrivateKey, r.ReplacesCertID = variant.KeyType, variant.PrivateKey, variant.ReplacesCertID
+		// 客户端按第一张证书的密钥类型创建，其他密钥类型需要自行生成私钥
+		if r.PrivateKey == nil && r.CSR == nil {
+			if r.PrivateKey, err = certcrypto.GeneratePrivateKey(r.KeyType); err != nil {
+				return results, errors.Wrap(err, "failure to generate private key")
+			}
+		}
+		res, err := s.obtain(client, &r)
+		// 第一张之后的证书授权已验证，重试不会再次进行域名验证
+		if err != nil && i > 0 && ctx.Err() == nil {
+			s.logger.Warn("failure to obtain cert, retrying", zap.Strings("domains", r.Domains),
+				zap.String("key_type", string(r.KeyType)), zap.Error(err))
+			res, err = s.obtain(client, &r)
+		}
+		if err != nil {
+			return results, errors.Wrapf(err, "failure to obtain %s cert", r.KeyType)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// prepareObtain 检查CAA并创建设置好验证器的客户端，返回的 release 需要在签发结束后调用
+func (s *AcmeCertServiceImpl) prepareObtain(ctx context.Context, req *ObtainCertReq) (*lego.Client, func(), error) {
+	if model.NewIdentifiers(req.Domains).HasIP() && (req.Solver == model.SolverDNS01 || req.Solver == "") {
+		return nil, nil, ErrIPRequiresHTTPOrALPN
+	}
+
+	account, err := s.acmeAccountService.GetAccount(ctx, &GetAccountReq{ID: req.AccountID})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	core, err := NewLegoCore(ctx, account)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = s.CheckCAA(ctx, &CheckCAAReq{Domains: req.Domains, Solver: req.Solver,
+		CAAIdentities: core.GetDirectory().Meta.CaaIdentities, AccountURI: AccountURI(account)})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := NewLegoClient(ctx, account, req.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.setChallengeSolver(ctx, client, req); err != nil {
+		return nil, nil, err
+	}
+
+	// 内置监听服务的验证方式在签发期间独占监听地址，同一地址的签发需要排队
+	release := func() {}
+	if address, ok := s.solverListenAddress(req.Solver); ok {
+		if release, err = acquireListenAddress(ctx, address); err != nil {
+			return nil, nil, err
+		}
+	}
+	return client, release, nil
+}
+
+// obtain 使用已设置验证器的客户端申请证书
+func (s *AcmeCertServiceImpl) obtain(client *lego.Client, req *ObtainCertReq) (*certificate.Resource, error) {
+	var cert *certificate.Resource
+	var err error
+	switch {
+	case req.CSR != nil:
+		cert, err = client.Certificate.ObtainForCSR(certificate.ObtainForCSRRequest{CSR: req.CSR, Bundle: true,
+			PreferredChain: req.PreferredChain, Profile: req.Profile, ReplacesCertID: req.ReplacesCertID})
+	case model.NewIdentifiers(req.Domains).HasIP():
+		// lego 会把第一个标识作为CN，IP标识需要自行生成CN为域名或为空的CSR
+		cert, err = s.obtainForIdentifiers(client, req)
+	default:
+		r := certificate.ObtainRequest{Domains: req.Domains, Bundle: true, MustStaple: req.MustStaple, PrivateKey: req.PrivateKey,
+			PreferredChain: req.PreferredChain, Profile: req.Profile, ReplacesCertID: req.ReplacesCertID}
+		cert, err = client.Certificate.Obtain(r)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to obtain cert")
+	}
+	return cert, nil
+}
+
+// obtainForIdentifiers 生成私钥和包含IP SAN的CSR后申请证书
+func (s *AcmeCertServiceImpl) obtainForIdentifiers(client *lego.Client, req *ObtainCertReq) (*certificate.Resource, error) {
+	privateKey := req.PrivateKey
+	if privateKey == nil {
+		keyType := req.KeyType
+		if keyType == "" {
+			keyType = certcrypto.RSA2048
+		}
+		var err error
+		privateKey, err = certcrypto.GeneratePrivateKey(keyType)
+		if err != nil {
+			return nil, errors.Wrap(err, "failure to generate private key")
+		}
+	}
+	csrDER, err := CreateCSR(privateKey, req.Domains, req.MustStaple)
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to create csr")
+	}
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to parse csr")
+	}
+	return client.Certificate.ObtainForCSR(certificate.ObtainForCSRRequest{CSR: csr, PrivateKey: privateKey, Bundle: true,
+		PreferredChain: req.PreferredChain, Profile: req.Profile, ReplacesCertID: req.ReplacesCertID})
+}
+
+// setChallengeSolver 根据验证方式为lego客户端设置对应的验证器
+func (s *AcmeCertServiceImpl) setChallengeSolver(ctx context.Context, client *lego.Client, req *ObtainCertReq) error {
+	switch req.Solver {
+	case model.SolverDNS01, "":
+		if req.DNSProviderID == "" && len(req.DNSRoutes) == 0 {
+			return errors.New("DNS-01 自动验证需要指定DNS提供商")
+		}
+		provider, err := s.dnsService.CreateRoutedDNSProvider(ctx, &CreateRoutedDNSProviderReq{
+			DefaultProviderID: req.DNSProviderID, Routes: req.DNSRoutes, Domains: req.Domains})
+		if err != nil {
+			return errors.Wrap(err, "Create provider failed")
+		}
+		// 使用自己的传播检查替换lego的预检查，直接查询每个权威DNS服务器并上报状态
+		preCheck := func(domain, fqdn, value string, _ dns01.PreCheckFunc) (bool, error) {
+			err := s.propagationService.Wait(ctx, &CheckPropagationReq{Domain: domain, FQDN: fqdn, Value: value}, req.OnDNSRecord)
+			return err == nil, err
+		}
+		err = client.Challenge.SetDNS01Provider(provider,
+			dns01.AddRecursiveNameservers(s.propagationService.Resolvers()), dns01.WrapPreCheck(preCheck))
+		if err != nil {
+			return errors.Wrap(err, "设置 DNS-01 Provider 失败")
+		}
+	case model.SolverHTTP01Standalone:
+		address, _ := s.solverListenAddress(req.Solver)
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return errors.Wrap(err, "invalid http01_address")
+		}
+		if err := client.Challenge.SetHTTP01Provider(http01.NewProviderServer(host, port)); err != nil {
+			return errors.Wrap(err, "设置 HTTP-01 Provider 失败")
+		}
+	case model.SolverHTTP01Webroot:
+		path, err := s.ResolveWebroot(req.Webroot)
+		if err != nil {
+			return err
+		}
+		provider, err := webroot.NewHTTPProvider(path)
+		if err != nil {
+			return errors.Wrap(err, "Create webroot provider failed")
+		}
+		if err := client.Challenge.SetHTTP01Provider(provider); err != nil {
+			return errors.Wrap(err, "设置 HTTP-01 Provider 失败")
+		}
+	case model.SolverTLSALPN01:
+		address, _ := s.solverListenAddress(req.Solver)
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return errors.Wrap(err, "invalid tlsalpn01_address")
+		}
+		if err := client.Challenge.SetTLSALPN01Provider(tlsalpn01.NewProviderServer(host, port)); err != nil {
+			return errors.Wrap(err, "设置 TLS-ALPN-01 Provider 失败")
+		}
+	default:
+		return errors.New("unsupported solver: " + string(req.Solver))
+	}
+	return nil
+}
+
+// solverListenAddress 返回需要内置监听服务的验证方式使用的地址
+func (s *AcmeCertServiceImpl) solverListenAddress(solver model.ChallengeSolver) (string, bool) {
+	switch solver {
+	case model.SolverHTTP01Standalone:
+		if s.conf.Challenge.HTTP01Address == "" {
+			return ":80", true
+		}
+		return s.conf.Challenge.HTTP01Address, true
+	case model.SolverTLSALPN01:
+		if s.conf.Challenge.TLSALPN01Address == "" {
+			return ":443", true
+		}
+		return s.conf.Challenge.TLSALPN01Address, true
+	}
+	return "", false
+}
+
+// listenAddressLocks 每个监听地址一个容量为1的信号量，同一时间只允许一次签发监听该地址
+var listenAddressLocks sync.Map
+
+// acquireListenAddress 等待获取监听地址，ctx 取消时放弃等待
+func acquireListenAddress(ctx context.Context, address string) (func(), error) {
+	value, _ := listenAddressLocks.LoadOrStore(address, make(chan struct{}, 1))
+	sem := value.(chan struct{})
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "等待监听地址 "+address+" 被取消")
+	}
+}
+
+// ResolveWebroot 返回证书使用的 webroot 目录，为空时使用默认目录。只允许配置中列出的目录，避免在任意路径下写入文件
+func (s *AcmeCertServiceImpl) ResolveWebroot(path string) (string, error) {
+	if path == "" {
+		path = s.conf.Challenge.HTTP01Webroot
+	}
+	if path == "" {
+		return "", errors.New("HTTP-01 webroot 验证需要指定webroot目录")
+	}
+	path = filepath.Clean(path)
+	for _, allowed := range append([]string{s.conf.Challenge.HTTP01Webroot}, s.conf.Challenge.HTTP01Webroots...) {
+		if allowed != "" && filepath.Clean(allowed) == path {
+			return path, nil
+		}
+	}
+	return "", errors.Errorf("webroot目录 %s 不在配置允许的目录中", path)
+}
+
+type RenewCertReq struct {
+	ID string
+	// IfVersion 非空时仅在当前生效版本仍为该版本时续期，后台续期据此跳过已被其他任务续期的证书
+	IfVersion string
+	// OnDNSRecord DNS-01 等待TXT记录生效期间上报每条记录的传播状态，可为空
+	OnDNSRecord func(status *model.DNSRecordStatus)
+}
+
+// renewLockTTL 续期锁的有效期，超过该时间视为上次续期异常中断，锁可被重新获取
+const renewLockTTL = time.Hour
+
+// ErrCertRenewing 证书正在由其他任务续期
+var ErrCertRenewing = errors.New("证书正在续期中，请稍后重试")
+
+// RenewCert 使用证书记录中保存的账户、密钥类型、域名和DNS提供商重新申请证书，并将结果写回该记录。证书对中的证书一起续期
+func (s *AcmeCertServiceImpl) RenewCert(ctx context.Context, req *RenewCertReq) error {
+	cert, err := s.GetCert(ctx, &GetCertReq{ID: req.ID})
+	if err != nil {
+		return err
+	}
+	certs := []model.AcmeCert{*cert}
+	if cert.PairID != "" {
+		if certs, err = s.GetCertPair(ctx, &GetCertPairReq{ID: req.ID}); err != nil {
+			return err
+		}
+	}
+
+	// 后台续期、手动续期和续期任务可能同时续期同一证书，先锁定证书（证书对锁定整对）
+	ids := make([]string, 0, len(certs))
+	for _, c := range certs {
+		ids = append(ids, c.ID)
+	}
+	if err := s.lockRenew(ids); err != nil {
+		return err
+	}
+	defer s.unlockRenew(ids)
+
+	// 加锁后重新读取，读取证书到加锁之间证书可能已被其他任务续期
+	if cert, err = s.GetCert(ctx, &GetCertReq{ID: req.ID}); err != nil {
+		return err
+	}
+	if req.IfVersion != "" && cert.ActiveVersionID != req.IfVersion {
+		s.logger.Info("Certificate already renewed, skipped", zap.String("cert_id", cert.ID))
+		return nil
+	}
+	if cert.PairID == "" {
+		return s.renewCert(ctx, cert, req.OnDNSRecord)
+	}
+	if certs, err = s.GetCertPair(ctx, &GetCertPairReq{ID: req.ID}); err != nil {
+		return err
+	}
+	return s.renewCertPair(ctx, certs, req.OnDNSRecord)
+}
+
+// lockRenew 以条件更新 renewing_at 逐个锁定证书，任一证书已被锁定时释放已获取的锁并返回 ErrCertRenewing
+func (s *AcmeCertServiceImpl) lockRenew(ids []string) error {
+	sort.Strings(ids)
+	for i, id := range ids {
+		now := time.Now()
+		result := s.db.Model(&model.AcmeCert{}).
+			Where("id = ? AND (renewing_at IS NULL OR renewing_at < ?)", id, now.Add(-renewLockTTL)).
+			Update("renewing_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			s.unlockRenew(ids[:i])
+			if result.Error != nil {
+				return errors.Wrap(result.Error, "failure to lock cert for renewal")
+			}
+			return ErrCertRenewing
+		}
+	}
+	return nil
+}
+
+func (s *AcmeCertServiceImpl) unlockRenew(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	if err := s.db.Model(&model.AcmeCert{}).Where("id IN ?", ids).Update("renewing_at", nil).Error; err != nil {
+		s.logger.Error("failure to unlock cert renewal", zap.Strings("cert_ids", ids), zap.Error(err))
+	}
+}
+
+// renewCert 续期单张证书，调用方需先通过 lockRenew 锁定证书
+func (s *AcmeCertServiceImpl) renewCert(ctx context.Context, cert *model.AcmeCert, onDNSRecord func(status *model.DNSRecordStatus)) error {
+	req, reuseCount, err := s.renewRequest(cert)
+	if err != nil {
+		return err
+	}
+	req.OnDNSRecord = onDNSRecord
+
+	now := time.Now()
+	res, err := s.ObtainCert(ctx, req)
+	if err != nil {
+		s.recordRenewError(cert, now, err, false)
+		return errors.Wrap(err, "failure to renew cert")
+	}
+	return s.saveRenewedCert(ctx, cert, res, reuseCount, now)
+}
+
+// renewCertPair 在一次操作中续期证书对，CAA检查和域名验证只进行一次。
+// 上次部分失败时只补签未续期的一半；本次部分失败时未签发的一半标记为待补签
+func (s *AcmeCertServiceImpl) renewCertPair(ctx context.Context, certs []model.AcmeCert, onDNSRecord func(status *model.DNSRecordStatus)) error {
+	var pending []model.AcmeCert
+	for _, c := range certs {
+		if c.RenewPending {
+			pending = append(pending, c)
+		}
+	}
+	if len(pending) == 0 {
+		pending = certs
+	}
+
+	var req *ObtainCertReq
+	variants := make([]ObtainVariant, 0, len(pending))
+	reuseCounts := make([]int, 0, len(pending))
+	for i := range pending {
+		r, reuseCount, err := s.renewRequest(&pending[i])
+		if err != nil {
+			return err
+		}
+		if req == nil {
+			req = r
+		}
+		variants = append(variants, ObtainVariant{KeyType: r.KeyType, PrivateKey: r.PrivateKey, ReplacesCertID: r.ReplacesCertID})
+		reuseCounts = append(reuseCounts, reuseCount)
+	}
+	req.OnDNSRecord = onDNSRecord
+
+	now := time.Now()
+	results, obtainErr := s.ObtainCertPair(ctx, req, variants)
+	for i := range pending {
+		if i < len(results) {
+			if err := s.saveRenewedCert(ctx, &pending[i], results[i], reuseCounts[i], now); err != nil {
+				return err
+			}
+			continue
+		}
+		// 已有证书续期成功时，剩下的证书标记为待补签，下次只续期这一半
+		s.recordRenewError(&pending[i], now, obtainErr, len(results) > 0)
+	}
+	if obtainErr != nil {
+		return errors.Wrap(obtainErr, "failure to renew cert pair")
+	}
+	return nil
+}
+
+// renewRequest 根据证书记录生成续期请求，返回续期后私钥的复用次数
+func (s *AcmeCertServiceImpl) renewRequest(cert *model.AcmeCert) (*ObtainCertReq, int, error) {
+	if cert.IsExternal() {
+		return nil, 0, ErrExternalCert
+	}
+	if cert.Solver == model.SolverDNS01 && !cert.HasDNSProvider() {
+		return nil, 0, errors.New("手动验证的证书不支持自动续期")
+	}
+
+	var err error
+	replacesCertID := cert.ARICertID
+	if replacesCertID == "" {
+		replacesCertID, err = makeARICertID(cert.Certificate)
+		if err != nil {
+			s.logger.Warn("failure to make ARI cert id", zap.String("cert_id", cert.ID), zap.Error(err))
+		}
+	}
+
+	// 使用CSR签发的证书没有私钥，续期时继续使用原CSR
+	var csr *x509.CertificateRequest
+	if cert.PrivateKey == "" && cert.CSR != "" {
+		csr, err = certcrypto.PemDecodeTox509CSR([]byte(cert.CSR))
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "failure to parse stored csr")
+		}
+	}
+
+	// 按私钥策略决定是否复用现有私钥
+	var privateKey crypto.PrivateKey
+	reuse, reuseCount := keyReuse(cert)
+	if reuse && csr == nil {
+		privateKey, err = certcrypto.ParsePEMPrivateKey([]byte(cert.PrivateKey))
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "failure to parse stored private key")
+		}
+	}
+
+	return &ObtainCertReq{
+		CSR:            csr,
+		PrivateKey:     privateKey,
+		KeyType:        cert.KeyType,
+		AccountID:      cert.AccountID,
+		Domains:        cert.Domains,
+		Solver:         cert.Solver,
+		DNSProviderID:  cert.DNSProviderID,
+		DNSRoutes:      cert.DNSRoutes,
+		Webroot:        cert.Webroot,
+		PreferredChain: cert.PreferredChain,
+		Profile:        cert.Profile,
+		MustStaple:     cert.MustStaple,
+		ReplacesCertID: replacesCertID,
+	}, reuseCount, nil
+}
+
+// recordRenewError 记录续期失败原因，pending 为 true 时将证书标记为证书对中待补签的一半
+func (s *AcmeCertServiceImpl) recordRenewError(cert *model.AcmeCert, now time.Time, renewErr error, pending bool) {
+	updates := map[string]interface{}{
+		"last_renew_at":    now,
+		"last_renew_error": renewErr.Error(),
+	}
+	if pending {
+		updates["renew_pending"] = true
+	}
+	if err := s.db.Model(&model.AcmeCert{}).Where("id = ?", cert.ID).Updates(updates).Error; err != nil {
+		s.logger.Error("failure to record renew error", zap.String("cert_id", cert.ID), zap.Error(err))
+	}
+}
+
+// saveRenewedCert 保存续期得到的证书并创建新的签发版本
+func (s *AcmeCertServiceImpl) saveRenewedCert(ctx context.Context, cert *model.AcmeCert, res *certificate.Resource, reuseCount int, now time.Time) error {
+	certInfo := ParseCertInfo(s.logger, string(res.Certificate))
+	updates := map[string]interface{}{
+		"cert_type":          certInfo.CertType,
+		"cert_status":        model.Issued,
+		"issued_at":          certInfo.IssuedAt,
+		"validity_days":      certInfo.ValidityDays,
+		"cert_url":           res.CertURL,
+		"cert_stable_url":    res.CertStableURL,
+		"private_key":        string(res.PrivateKey),
+		"certificate":        string(res.Certificate),
+		"issuer_certificate": string(res.IssuerCertificate),
+		"csr":                string(res.CSR),
+		"last_renew_at":      now,
+		"last_renew_error":   "",
+		"key_reuse_count":    reuseCount,
+		"renew_pending":      false,
+	}
+	resetCertState(updates)
+	if err := s.db.Model(&model.AcmeCert{}).Where("id = ?", cert.ID).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "failure to update renewed cert")
+	}
+	if _, err := s.CreateCertVersion(ctx, &CreateCertVersionReq{CertID: cert.ID}); err != nil {
+		return err
+	}
+
+	s.logger.Info("Certificate renewed successfully", zap.String("cert_id", cert.ID), zap.Strings("domains", cert.Domains))
+
+	if err := s.RefreshRenewalInfo(ctx, &RefreshRenewalInfoReq{ID: cert.ID}); err != nil {
+		s.logger.Warn("failure to refresh renewal info", zap.String("cert_id", cert.ID), zap.Error(err))
+	}
+	return nil
+}
+
+// resetCertState 清除属于旧证书的续期窗口和OCSP状态，更换证书内容时使用
+func resetCertState(updates map[string]interface{}) {
+	// 续期窗口需要针对新证书重新获取
+	updates["ari_cert_id"] = ""
+	// 吊销信息属于旧证书
+	updates["revocation_reason"] = ""
+	updates["revoked_at"] = nil
+	updates["renewal_window_start"] = nil
+	updates["renewal_window_end"] = nil
+	updates["renewal_explanation_url"] = ""
+	updates["renewal_info_retry_at"] = nil
+	updates["ocsp_status"] = ""
+	updates["ocsp_response"] = nil
+	updates["ocsp_checked_at"] = nil
+	updates["ocsp_next_update"] = nil
+}
+
+// keyReuse 根据证书的私钥策略判断本次续期是否复用私钥，并返回续期后私钥的复用次数
+func keyReuse(cert *model.AcmeCert) (bool, int) {
+	switch cert.KeyPolicy {
+	case model.KeyPolicyReuse:
+		return true, cert.KeyReuseCount + 1
+	case model.KeyPolicyRotateEvery:
+		if cert.KeyRotateEvery > 0 && cert.KeyReuseCount+1 < cert.KeyRotateEvery {
+			return true, cert.KeyReuseCount + 1
+		}
+	}
+	return false, 0
+}
+
+type UpdateKeyPolicyReq struct {
+	ID             string          `json:"-"`
+	KeyPolicy      model.KeyPolicy `json:"key_policy" binding:"required"`
+	KeyRotateEvery int             `json:"key_rotate_every"`
+}
+
+// UpdateKeyPolicy 修改证书续期时的私钥策略
+func (s *AcmeCertServiceImpl) UpdateKeyPolicy(ctx context.Context, req *UpdateKeyPolicyReq) error {
+	if err := ValidateKeyPolicy(req.KeyPolicy, req.KeyRotateEvery); err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"key_policy":       req.KeyPolicy,
+		"key_rotate_every": req.KeyRotateEvery,
+	}
+	if err := s.db.Model(&model.AcmeCert{}).Where("id = ?", req.ID).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "failure to update key policy")
+	}
+	return nil
+}
+
+type UpdateDNSRoutesReq struct {
+	ID            string          `json:"-"`
+	DNSProviderID string          `json:"dns_provider_id"`
+	DNSRoutes     model.DNSRoutes `json:"dns_routes"`
+}
+
+// UpdateDNSRoutes 修改DNS-01证书的默认DNS提供商和按区域的路由表并开启自动续期，证书对中的证书一起修改
+func (s *AcmeCertServiceImpl) UpdateDNSRoutes(ctx context.Context, req *UpdateDNSRoutesReq) error {
+	cert, err := s.GetCert(ctx, &GetCertReq{ID: req.ID})
+	if err != nil {
+		return err
+	}
+	if cert.IsExternal() {
+		return ErrExternalCert
+	}
+	if cert.Solver != model.SolverDNS01 {
+		return errors.New("只有DNS-01验证的证书可以设置DNS路由")
+	}
+	if req.DNSProviderID == "" && len(req.DNSRoutes) == 0 {
+		return errors.New("DNS-01 自动验证需要指定DNS提供商")
+	}
+	if err := ValidateDNSRoutes(req.DNSRoutes, req.DNSProviderID, cert.Domains); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"dns_provider_id": req.DNSProviderID,
+		"dns_routes":      req.DNSRoutes,
+		"auto_renew":      true, // 配置了DNS提供商即可自动续期
+	}
+	query := s.db.Model(&model.AcmeCert{})
+	if cert.PairID != "" {
+		query = query.Where("pair_id = ?", cert.PairID)
+	} else {
+		query = query.Where("id = ?", cert.ID)
+	}
+	if err := query.Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "failure to update dns routes")
+	}
+	return nil
+}
+
+// ValidateKeyPolicy 校验私钥策略，rotate_every 需要指定至少为2的续期次数
+func ValidateKeyPolicy(policy model.KeyPolicy, rotateEvery int) error {
+	if !policy.IsValid() {
+		return errors.New("invalid key policy: " + string(policy))
+	}
+	if policy == model.KeyPolicyRotateEvery && rotateEvery < 2 {
+		return errors.New("rotate_every 策略的 key_rotate_every 至少为2")
+	}
+	return nil
+}
+
+// GetCertsDueForRenewal 查询已签发、开启自动续期且已进入续期窗口的证书。
+// 优先使用CA通过ARI给出的建议窗口，没有时退回到距离到期不足 renewBeforeDays 天
+func (s *AcmeCertServiceImpl) GetCertsDueForRenewal(ctx context.Context, renewBeforeDays int) ([]model.AcmeCert, error) {
+	var certs []model.AcmeCert
+	err := s.db.Model(&model.AcmeCert{}).
+		Where("source = ?", model.CertSourceACME).
+		Where("cert_status = ? AND auto_renew AND (solver <> ? OR dns_provider_id <> '' OR jsonb_array_length(COALESCE(dns_routes, '[]')) > 0)", model.Issued, model.SolverDNS01).
+		Where("renew_pending OR (renewal_window_start IS NOT NULL AND renewal_window_start <= NOW()) OR "+
+			"(renewal_window_start IS NULL AND issued_at IS NOT NULL AND issued_at + ((validity_days - ?) || ' days')::interval <= NOW())", renewBeforeDays).
+		Order("issued_at asc").
+		Find(&certs).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to query certs due for renewal")
+	}
+	return certs, nil
+}
+
+// defaultRenewalInfoRetry CA未返回 Retry-After 或不支持ARI时，再次查询续期信息的间隔
+const defaultRenewalInfoRetry = 24 * time.Hour
+
+type RefreshRenewalInfoReq struct {
+	ID string
+}
+
+// RefreshRenewalInfo 向CA查询证书的建议续期窗口（ARI, RFC 9773）并保存到证书记录
+func (s *AcmeCertServiceImpl) RefreshRenewalInfo(ctx context.Context, req *RefreshRenewalInfoReq) error {
+	cert, err := s.GetCert(ctx, &GetCertReq{ID: req.ID})
+	if err != nil {
+		return err
+	}
+	if cert.IsExternal() {
+		return ErrExternalCert
+	}
+
+	leaf, err := certcrypto.ParsePEMCertificate([]byte(cert.Certificate))
+	if err != nil {
+		return errors.Wrap(err, "failure to parse certificate")
+	}
+	ariCertID, err := certificate.MakeARICertID(leaf)
+	if err != nil {
+		return errors.Wrap(err, "failure to make ARI cert id")
+	}
+
+	account, err := s.acmeAccountService.GetAccount(ctx, &GetAccountReq{ID: cert.AccountID})
+	if err != nil {
+		return err
+	}
+	client, err := NewLegoClient(ctx, account, "")
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"ari_cert_id": ariCertID,
+	}
+	info, err := client.Certificate.GetRenewalInfo(certificate.RenewalInfoRequest{Cert: leaf})
+	if err != nil {
+		if !errors.Is(err, api.ErrNoARI) {
+			return errors.Wrap(err, "failure to get renewal info")
+		}
+		// CA不支持ARI，使用固定天数的续期策略
+		updates["renewal_window_start"] = nil
+		updates["renewal_window_end"] = nil
+		updates["renewal_explanation_url"] = ""
+		updates["renewal_info_retry_at"] = time.Now().Add(defaultRenewalInfoRetry)
+	} else {
+		retry := info.RetryAfter
+		if retry <= 0 {
+			retry = defaultRenewalInfoRetry
+		}
+		updates["renewal_window_start"] = info.SuggestedWindow.Start
+		updates["renewal_window_end"] = info.SuggestedWindow.End
+		updates["renewal_explanation_url"] = info.ExplanationURL
+		updates["renewal_info_retry_at"] = time.Now().Add(retry)
+	}
+
+	if err := s.db.Model(&model.AcmeCert{}).Where("id = ?", req.ID).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "failure to update renewal info")
+	}
+	return nil
+}
+
+// GetCertsDueForRenewalInfo 查询需要（重新）获取ARI续期信息的已签发证书
+func (s *AcmeCertServiceImpl) GetCertsDueForRenewalInfo(ctx context.Context) ([]model.AcmeCert, error) {
+	var certs []model.AcmeCert
+	err := s.db.Model(&model.AcmeCert{}).
+		Where("cert_status = ? AND source = ?", model.Issued, model.CertSourceACME).
+		Where("renewal_info_retry_at IS NULL OR renewal_info_retry_at <= NOW()").
+		Find(&certs).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to query certs due for renewal info")
+	}
+	return certs, nil
+}
+
+// CertPairKeyTypes 证书对包含的密钥类型，ECDSA证书在前
+var CertPairKeyTypes = []certcrypto.KeyType{certcrypto.EC256, certcrypto.RSA2048}
+
+type GetCertPairReq struct {
+	ID string
+}
+
+// GetCertPair 查询证书所在证书对的全部证书
+func (s *AcmeCertServiceImpl) GetCertPair(ctx context.Context, req *GetCertPairReq) ([]model.AcmeCert, error) {
+	cert, err := s.GetCert(ctx, &GetCertReq{ID: req.ID})
+	if err != nil {
+		return nil, err
+	}
+	if cert.PairID == "" {
+		return nil, errors.New("该证书不属于证书对")
+	}
+
+	var certs []model.AcmeCert
+	if err := s.db.Where("pair_id = ?", cert.PairID).Order("created_at asc").Find(&certs).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to get cert pair")
+	}
+	return certs, nil
+}
+
+type CheckOCSPReq struct {
+	ID string
+}
+
+// ocspTimeout 单次OCSP查询的超时时间
+const ocspTimeout = 30 * time.Second
+
+// CheckOCSP 向颁发者的OCSP服务查询证书状态并保存响应，CA报告已吊销时将证书标记为已吊销。
+// 使用保存的颁发者证书直接请求OCSP服务，签发账户已删除或停用的证书同样会检查
+func (s *AcmeCertServiceImpl) CheckOCSP(ctx context.Context, req *CheckOCSPReq) error {
+	cert, err := s.GetCert(ctx, &GetCertReq{ID: req.ID})
+	if err != nil {
+		return err
+	}
+	if cert.IsExternal() {
+		return ErrExternalCert
+	}
+
+	leaf, err := certcrypto.ParsePEMCertificate([]byte(cert.Certificate))
+	if err != nil {
+		return errors.Wrap(err, "failure to parse certificate")
+	}
+	now := time.Now()
+	if len(leaf.OCSPServer) == 0 {
+		updates := map[string]interface{}{"ocsp_status": model.OCSPNoResponder, "ocsp_checked_at": now}
+		err := s.db.Model(&model.AcmeCert{}).Where("id = ? AND certificate = ?", req.ID, cert.Certificate).Updates(updates).Error
+		if err != nil {
+			return errors.Wrap(err, "failure to update ocsp status")
+		}
+		return nil
+	}
+
+	issuers, err := certcrypto.ParsePEMBundle([]byte(cert.IssuerCertificate))
+	if err != nil || len(issuers) == 0 {
+		return errors.New("证书缺少颁发者证书，无法查询OCSP")
+	}
+	raw, resp, err := fetchOCSP(ctx, leaf, issuers[0])
+	if err != nil {
+		return errors.Wrap(err, "failure to get ocsp response")
+	}
+
+	updates := map[string]interface{}{
+		"ocsp_response":   raw,
+		"ocsp_checked_at": now,
+	}
+	if !resp.NextUpdate.IsZero() {
+		updates["ocsp_next_update"] = resp.NextUpdate
+	}
+	revoked := resp.Status == ocsp.Revoked
+	switch resp.Status {
+	case ocsp.Good:
+		updates["ocsp_status"] = model.OCSPGood
+	case ocsp.Revoked:
+		updates["ocsp_status"] = model.OCSPRevoked
+		updates["cert_status"] = model.Revoked
+		updates["revoked_at"] = resp.RevokedAt
+		updates["revocation_reason"] = model.RevocationReasonFromCode(resp.RevocationReason)
+		s.logger.Warn("Certificate revoked by CA", zap.String("cert_id", req.ID),
+			zap.Strings("domains", cert.Domains), zap.Time("revoked_at", resp.RevokedAt))
+	default:
+		updates["ocsp_status"] = model.OCSPUnknown
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 查询期间证书可能已续期，响应只属于查询时的证书，不能写到新证书上
+		result := tx.Model(&model.AcmeCert{}).Where("id = ? AND certificate = ?", req.ID, cert.Certificate).Updates(updates)
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "failure to update ocsp status")
+		}
+		if result.RowsAffected == 0 {
+			s.logger.Info("Certificate superseded during OCSP check", zap.String("cert_id", req.ID))
+		}
+		if !revoked {
+			return nil
+		}
+		// 同步吊销对应的签发版本，避免回滚到已被CA吊销的证书
+		err := tx.Model(&model.AcmeCertVersion{}).Where("cert_id = ? AND serial = ?", req.ID, fmt.Sprintf("%x", leaf.SerialNumber)).
+			Updates(map[string]interface{}{"status": model.CertVersionRevoked, "revoked_at": resp.RevokedAt,
+				"revocation_reason": updates["revocation_reason"]}).Error
+		if err != nil {
+			return errors.Wrap(err, "failure to revoke cert version")
+		}
+		return nil
+	})
+}
+
+// fetchOCSP 按RFC 6960构造OCSP请求并POST到证书的第一个OCSP地址，返回原始响应和解析结果
+func fetchOCSP(ctx context.Context, leaf, issuer *x509.Certificate) ([]byte, *ocsp.Response, error) {
+	body, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failure to create ocsp request")
+	}
+	ctx, cancel := context.WithTimeout(ctx, ocspTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, nil, errors.Errorf("ocsp responder returned %s", httpResp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failure to parse ocsp response")
+	}
+	return raw, resp, nil
+}
+
+// GetCertsForOCSPCheck 查询需要检查OCSP状态的已签发且未过期的证书
+func (s *AcmeCertServiceImpl) GetCertsForOCSPCheck(ctx context.Context) ([]model.AcmeCert, error) {
+	var certs []model.AcmeCert
+	err := s.db.Model(&model.AcmeCert{}).
+		Where("cert_status = ? AND source = ? AND (ocsp_status IS NULL OR ocsp_status <> ?)", model.Issued, model.CertSourceACME, model.OCSPNoResponder).
+		Where("issued_at IS NULL OR issued_at + (validity_days || ' days')::interval > NOW()").
+		Find(&certs).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to query certs for ocsp check")
+	}
+	return certs, nil
+}
+
+// CertChain CA为证书提供的一条证书链
+type CertChain struct {
+	URL      string   `json:"url"`
+	Issuer   string   `json:"issuer"`   // 链顶端证书的颁发者CN，可作为 preferred_chain 使用
+	Subjects []string `json:"subjects"` // 链中各证书的CN，从叶子证书开始
+	Current  bool     `json:"current"`  // 是否为当前使用的证书链
+}
+
+type ListCertChainsReq struct {
+	ID string
+}
+
+// ListCertChains 从CA获取证书的默认链和所有备用链
+func (s *AcmeCertServiceImpl) ListCertChains(ctx context.Context, req *ListCertChainsReq) ([]CertChain, error) {
+	cert, certs, err := s.getCertChains(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var chains []CertChain
+	for url, raw := range certs {
+		bundle, err := certcrypto.ParsePEMBundle(raw.Cert)
+		if err != nil {
+			return nil, errors.Wrap(err, "failure to parse certificate chain")
+		}
+		chain := CertChain{
+			URL:     url,
+			Issuer:  bundle[len(bundle)-1].Issuer.CommonName,
+			Current: string(raw.Cert) == cert.Certificate,
+		}
+		for _, c := range bundle {
+			chain.Subjects = append(chain.Subjects, c.Subject.CommonName)
+		}
+		chains = append(chains, chain)
+	}
+	// 默认链排在最前面
+	sort.SliceStable(chains, func(i, j int) bool {
+		return chains[i].URL == cert.CertURL && chains[j].URL != cert.CertURL
+	})
+	return chains, nil
+}
+
+type SwitchCertChainReq struct {
+	ID  string
+	URL string `json:"url" binding:"required"`
+}
+
+// SwitchCertChain 将证书切换为CA提供的另一条证书链，无需重新签发，并记录为后续续期的首选链
+func (s *AcmeCertServiceImpl) SwitchCertChain(ctx context.Context, req *SwitchCertChainReq) error {
+	cert, certs, err := s.getCertChains(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	raw, ok := certs[req.URL]
+	if !ok {
+		return errors.New("证书链不存在")
+	}
+	bundle, err := certcrypto.ParsePEMBundle(raw.Cert)
+	if err != nil {
+		return errors.Wrap(err, "failure to parse certificate chain")
+	}
+
+	updates := map[string]interface{}{
+		"certificate":        string(raw.Cert),
+		"issuer_certificate": string(raw.Issuer),
+		"preferred_chain":    bundle[len(bundle)-1].Issuer.CommonName,
+	}
+	if err := s.db.Model(&model.AcmeCert{}).Where("id = ?", req.ID).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "failure to switch cert chain")
+	}
+	// 切换证书链不产生新版本，只更新当前版本的证书内容
+	if cert.ActiveVersionID != "" {
+		err := s.db.Model(&model.AcmeCertVersion{}).Where("id = ?", cert.ActiveVersionID).
+			Updates(map[string]interface{}{"certificate": string(raw.Cert), "issuer_certificate": string(raw.Issuer)}).Error
+		if err != nil {
+			return errors.Wrap(err, "failure to switch cert version chain")
+		}
+	}
+	return nil
+}
+
+// getCertChains 通过证书URL获取CA提供的所有证书链，key为链的下载地址
+func (s *AcmeCertServiceImpl) getCertChains(ctx context.Context, id string) (*model.AcmeCert, map[string]*acme.RawCertificate, error) {
+	cert, err := s.GetCert(ctx, &GetCertReq{ID: id})
+	if err != nil {
+		return nil, nil, err
+	}
+	if cert.IsExternal() {
+		return nil, nil, ErrExternalCert
+	}
+	if cert.CertURL == "" {
+		return nil, nil, errors.New("证书没有下载地址，无法获取证书链")
+	}
+
+	account, err := s.acmeAccountService.GetAccount(ctx, &GetAccountReq{ID: cert.AccountID})
+	if err != nil {
+		return nil, nil, err
+	}
+	core, err := NewLegoCore(ctx, account)
+	if err != nil {
+		return nil, nil, err
+	}
+	certs, err := core.Certificates.GetAll(cert.CertURL, true)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failure to get cert chains")
+	}
+	return cert, certs, nil
+}
+
+// ParseCSR 解析PEM格式的CSR，并检查其中的域名与申请的域名完全一致
+func ParseCSR(csrPEM string, domains []string) (*x509.CertificateRequest, error) {
+	csr, err := certcrypto.PemDecodeTox509CSR([]byte(csrPEM))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid csr")
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, errors.Wrap(err, "invalid csr signature")
+	}
+
+	csrDomains := certcrypto.ExtractDomainsCSR(csr)
+	if len(csrDomains) == 0 {
+		return nil, errors.New("CSR中没有域名")
+	}
+	want := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		want[strings.ToLower(domain)] = true
+	}
+	got := make(map[string]bool, len(csrDomains))
+	for _, domain := range csrDomains {
+		domain = strings.ToLower(domain)
+		if !want[domain] {
+			return nil, errors.Errorf("CSR中的域名 %s 不在申请的域名中", domain)
+		}
+		got[domain] = true
+	}
+	for domain := range want {
+		if !got[domain] {
+			return nil, errors.Errorf("申请的域名 %s 不在CSR中", domain)
+		}
+	}
+	return csr, nil
+}
+
+// CSRKeyType 根据CSR的公钥推断密钥类型
+func CSRKeyType(csr *x509.CertificateRequest) certcrypto.KeyType {
+	switch pub := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return certcrypto.KeyType(strconv.Itoa(pub.N.BitLen()))
+	case *ecdsa.PublicKey:
+		switch pub.Curve.Params().BitSize {
+		case 256:
+			return certcrypto.EC256
+		case 384:
+			return certcrypto.EC384
+		}
+	}
+	return ""
+}
+
+// makeARICertID 根据PEM证书计算ARI证书标识
+func makeARICertID(certPEM string) (string, error) {
+	leaf, err := certcrypto.ParsePEMCertificate([]byte(certPEM))
+	if err != nil {
+		return "", err
+	}
+	return certificate.MakeARICertID(leaf)
+}
+
+// CertInfo 证书信息结构体
+type CertInfo struct {
+	CertType     model.CertType
+	IssuedAt     *time.Time
+	ExpiresAt    *time.Time
+	ValidityDays int
+	Serial       string
+}
+
+// ParseCertInfo 解析证书信息，包括类型、签发时间和有效期
+func ParseCertInfo(logger *zap.Logger, certPEM string) *CertInfo {
+	// 解析证书
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		logger.Warn("无法解析证书PEM，使用默认值")
+		return &CertInfo{
+			CertType:     model.CertTypeDV,
+			IssuedAt:     nil,
+			ValidityDays: 0,
+		}
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		logger.Error("解析证书失败，使用默认值", zap.Error(err))
+		return &CertInfo{
+			CertType:     model.CertTypeDV,
+			IssuedAt:     nil,
+			ValidityDays: 0,
+		}
+	}
+
+	certType := model.CertTypeDV
+	if len(cert.Subject.Organization) > 0 || len(cert.Subject.OrganizationalUnit) > 0 {
+		certType = model.CertTypeOV
+		logger.Info("检测到组织信息，判断为OV证书")
+	} else {
+		logger.Info("未检测到组织信息，判断为DV证书")
+	}
+
+	// 获取签发时间（NotBefore）
+	issuedAt := cert.NotBefore
+
+	// 计算有效期天数
+	validityDuration := cert.NotAfter.Sub(cert.NotBefore)
+	validityDays := int(validityDuration.Hours() / 24)
+
+	logger.Info("解析证书信息成功",
+		zap.String("cert_type", string(certType)),
+		zap.Time("issued_at", issuedAt),
+		zap.Int("validity_days", validityDays),
+		zap.Time("expires_at", cert.NotAfter))
+
+	return &CertInfo{
+		CertType:     certType,
+		IssuedAt:     &issuedAt,
+		ExpiresAt:    &cert.NotAfter,
+		ValidityDays: validityDays,
+		Serial:       fmt.Sprintf("%x", cert.SerialNumber),
+	}
+}
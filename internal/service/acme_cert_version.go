@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"easyacme/internal/model"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type CreateCertVersionReq struct {
+	CertID string
+}
+
+// CreateCertVersion 将证书当前的签发结果保存为新版本并设为生效版本，原生效版本标记为已替换
+func (s *AcmeCertServiceImpl) CreateCertVersion(ctx context.Context, req *CreateCertVersionReq) (*model.AcmeCertVersion, error) {
+	var version *model.AcmeCertVersion
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var cert model.AcmeCert
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&cert, "id = ?", req.CertID).Error; err != nil {
+			return errors.Wrap(err, "failure to get cert")
+		}
+
+		var latest int
+		if err := tx.Model(&model.AcmeCertVersion{}).Where("cert_id = ?", cert.ID).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return errors.Wrap(err, "failure to query latest cert version")
+		}
+
+		info := ParseCertInfo(s.logger, cert.Certificate)
+		version = &model.AcmeCertVersion{Model: model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+			CertID: cert.ID, Version: latest + 1, ReplacesID: cert.ActiveVersionID, Serial: info.Serial, Status: model.CertVersionActive,
+			KeyType: cert.KeyType, IssuedAt: info.IssuedAt, ExpiresAt: info.ExpiresAt, ValidityDays: info.ValidityDays,
+			CertURL: cert.CertURL, CertStableURL: cert.CertStableURL, PrivateKey: cert.PrivateKey,
+			Certificate: cert.Certificate, IssuerCertificate: cert.IssuerCertificate, CSR: cert.CSR}
+
+		err := tx.Model(&model.AcmeCertVersion{}).Where("cert_id = ? AND status = ?", cert.ID, model.CertVersionActive).
+			Update("status", model.CertVersionSuperseded).Error
+		if err != nil {
+			return errors.Wrap(err, "failure to supersede cert version")
+		}
+		if err := tx.Create(version).Error; err != nil {
+			return errors.Wrap(err, "failure to create cert version")
+		}
+		if err := tx.Model(&model.AcmeCert{}).Where("id = ?", cert.ID).Update("active_version_id", version.ID).Error; err != nil {
+			return errors.Wrap(err, "failure to update active cert version")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+type ListCertVersionsReq struct {
+	CertID string
+}
+
+// ListCertVersions 按版本号倒序返回证书的全部签发版本
+func (s *AcmeCertServiceImpl) ListCertVersions(ctx context.Context, req *ListCertVersionsReq) ([]model.AcmeCertVersion, error) {
+	var versions []model.AcmeCertVersion
+	if err := s.db.Where("cert_id = ?", req.CertID).Order("version desc").Find(&versions).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to query cert versions")
+	}
+	return versions, nil
+}
+
+type GetActiveVersionReq struct {
+	CertID string
+}
+
+// GetActiveVersion 返回证书当前生效的版本
+func (s *AcmeCertServiceImpl) GetActiveVersion(ctx context.Context, req *GetActiveVersionReq) (*model.AcmeCertVersion, error) {
+	cert, err := s.GetCert(ctx, &GetCertReq{ID: req.CertID})
+	if err != nil {
+		return nil, err
+	}
+	if cert.ActiveVersionID == "" {
+		return nil, errors.New("证书尚未签发")
+	}
+	var version model.AcmeCertVersion
+	if err := s.db.First(&version, "id = ?", cert.ActiveVersionID).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to get active cert version")
+	}
+	return &version, nil
+}
+
+type RollbackCertVersionReq struct {
+	CertID    string
+	VersionID string `json:"version_id" binding:"required"`
+}
+
+// RollbackCertVersion 将生效版本切换回之前签发的某个版本，已吊销或已过期的版本不能回滚
+func (s *AcmeCertServiceImpl) RollbackCertVersion(ctx context.Context, req *RollbackCertVersionReq) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var cert model.AcmeCert
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&cert, "id = ?", req.CertID).Error; err != nil {
+			return errors.Wrap(err, "failure to get cert")
+		}
+		var version model.AcmeCertVersion
+		if err := tx.First(&version, "id = ? AND cert_id = ?", req.VersionID, req.CertID).Error; err != nil {
+			return errors.Wrap(err, "failure to get cert version")
+		}
+		switch {
+		case version.ID == cert.ActiveVersionID:
+			return errors.New("该版本已是当前生效版本")
+		case version.Status == model.CertVersionRevoked:
+			return errors.New("该版本已吊销，不能回滚")
+		case version.ExpiresAt != nil && version.ExpiresAt.Before(time.Now()):
+			return errors.New("该版本已过期，不能回滚")
+		}
+
+		err := tx.Model(&model.AcmeCertVersion{}).Where("cert_id = ? AND status = ?", cert.ID, model.CertVersionActive).
+			Update("status", model.CertVersionSuperseded).Error
+		if err != nil {
+			return errors.Wrap(err, "failure to supersede cert version")
+		}
+		if err := tx.Model(&model.AcmeCertVersion{}).Where("id = ?", version.ID).Update("status", model.CertVersionActive).Error; err != nil {
+			return errors.Wrap(err, "failure to activate cert version")
+		}
+
+		updates := map[string]interface{}{
+			"active_version_id":  version.ID,
+			"cert_status":        model.Issued,
+			"key_type":           version.KeyType,
+			"issued_at":          version.IssuedAt,
+			"validity_days":      version.ValidityDays,
+			"cert_url":           version.CertURL,
+			"cert_stable_url":    version.CertStableURL,
+			"private_key":        version.PrivateKey,
+			"certificate":        version.Certificate,
+			"issuer_certificate": version.IssuerCertificate,
+			"csr":                version.CSR,
+		}
+		resetCertState(updates)
+		if err := tx.Model(&model.AcmeCert{}).Where("id = ?", cert.ID).Updates(updates).Error; err != nil {
+			return errors.Wrap(err, "failure to rollback cert")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("Certificate rolled back", zap.String("cert_id", req.CertID), zap.String("version_id", req.VersionID))
+	if err := s.RefreshRenewalInfo(ctx, &RefreshRenewalInfoReq{ID: req.CertID}); err != nil {
+		s.logger.Warn("failure to refresh renewal info", zap.String("cert_id", req.CertID), zap.Error(err))
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"easyacme/internal/config"
+	"easyacme/internal/model"
+	"encoding/base64"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	acmeDNSTTL      = 1
+	acmeDNSTXTLen   = 43 // base64url 编码的SHA256摘要长度
+	acmeDNSQueryTTL = 5 * time.Second
+)
+
+// ErrAcmeDNSForbidden 用户名、密钥或来源地址校验失败
+var ErrAcmeDNSForbidden = errors.New("forbidden")
+
+// AcmeDNSService 内置 acme-dns 兼容服务：权威DNS服务器只应答委派域名下的 _acme-challenge TXT 记录，
+// 注册和更新接口与 acme-dns 一致，现有 acme-dns 客户端可直接使用
+type AcmeDNSService interface {
+	Start()
+	Stop()
+	Register(ctx context.Context, req *AcmeDNSRegisterReq) (*AcmeDNSRegisterResp, error)
+	Update(ctx context.Context, req *AcmeDNSUpdateReq) error
+	NewProvider(ctx context.Context, username, password string) (challenge.Provider, error)
+}
+
+type AcmeDNSServiceImpl struct {
+	db     *gorm.DB
+	logger *zap.Logger
+	conf   config.AcmeDNSConfig
+	zone   string
+
+	servers []*dns.Server
+}
+
+// NewAcmeDNSService .
+func NewAcmeDNSService(db *gorm.DB, logger *zap.Logger, cfg *config.Config) AcmeDNSService {
+	return &AcmeDNSServiceImpl{
+		db:     db,
+		logger: logger,
+		conf:   cfg.AcmeDNS,
+		zone:   strings.ToLower(dns.Fqdn(cfg.AcmeDNS.Domain)),
+	}
+}
+
+// Start 启动UDP和TCP的DNS服务
+func (s *AcmeDNSServiceImpl) Start() {
+	if !s.conf.Enabled {
+		s.logger.Info("acme-dns server is disabled")
+		return
+	}
+	if s.conf.Domain == "" || s.conf.NSName == "" {
+		s.logger.Error("acme-dns server requires domain and nsname")
+		return
+	}
+	listen := s.conf.Listen
+	if listen == "" {
+		listen = ":53"
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(s.zone, s.handleQuery)
+	for _, network := range []string{"udp", "tcp"} {
+		server := &dns.Server{Addr: listen, Net: network, Handler: mux}
+		s.servers = append(s.servers, server)
+		go func() {
+			if err := server.ListenAndServe(); err != nil {
+				s.logger.Error("acme-dns server stopped", zap.String("net", server.Net), zap.Error(err))
+			}
+		}()
+	}
+	s.logger.Info("acme-dns server started", zap.String("listen", listen), zap.String("zone", s.zone))
+}
+
+// Stop 停止DNS服务
+func (s *AcmeDNSServiceImpl) Stop() {
+	for _, server := range s.servers {
+		if err := server.Shutdown(); err != nil {
+			s.logger.Warn("failed to shutdown acme-dns server", zap.String("net", server.Net), zap.Error(err))
+		}
+	}
+	s.servers = nil
+}
+
+// handleQuery 应答委派域名的查询：子域名的TXT记录，以及域名本身的SOA、NS和A记录
+func (s *AcmeDNSServiceImpl) handleQuery(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	if len(r.Question) > 0 {
+		q := r.Question[0]
+		name := strings.ToLower(q.Name)
+		switch {
+		case name == s.zone:
+			s.answerZone(m, q)
+		case name == strings.ToLower(dns.Fqdn(s.conf.NSName)):
+			if q.Qtype == dns.TypeA && s.conf.IP != "" {
+				m.Answer = append(m.Answer, s.aRecord(q.Name))
+			} else {
+				m.Ns = append(m.Ns, s.soaRecord())
+			}
+		default:
+			s.answerSubdomain(m, q, strings.TrimSuffix(name, "."+s.zone))
+		}
+	}
+
+	if err := w.WriteMsg(m); err != nil {
+		s.logger.Warn("failed to write dns response", zap.Error(err))
+	}
+}
+
+func (s *AcmeDNSServiceImpl) answerZone(m *dns.Msg, q dns.Question) {
+	switch q.Qtype {
+	case dns.TypeSOA:
+		m.Answer = append(m.Answer, s.soaRecord())
+	case dns.TypeNS:
+		m.Answer = append(m.Answer, &dns.NS{
+			Hdr: dns.RR_Header{Name: s.zone, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 3600},
+			Ns:  dns.Fqdn(s.conf.NSName),
+		})
+	case dns.TypeA:
+		if s.conf.IP != "" {
+			m.Answer = append(m.Answer, s.aRecord(s.zone))
+			return
+		}
+		m.Ns = append(m.Ns, s.soaRecord())
+	default:
+		m.Ns = append(m.Ns, s.soaRecord())
+	}
+}
+
+func (s *AcmeDNSServiceImpl) answerSubdomain(m *dns.Msg, q dns.Question, subdomain string) {
+	ctx, cancel := context.WithTimeout(context.Background(), acmeDNSQueryTTL)
+	defer cancel()
+
+	var registration model.AcmeDNSRegistration
+	err := s.db.WithContext(ctx).First(&registration, "subdomain = ?", subdomain).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Error("failed to query acme-dns registration", zap.String("subdomain", subdomain), zap.Error(err))
+			m.Rcode = dns.RcodeServerFailure
+			return
+		}
+		m.Rcode = dns.RcodeNameError
+		m.Ns = append(m.Ns, s.soaRecord())
+		return
+	}
+
+	if q.Qtype != dns.TypeTXT || len(registration.TXT) == 0 {
+		m.Ns = append(m.Ns, s.soaRecord())
+		return
+	}
+	for _, txt := range registration.TXT {
+		m.Answer = append(m.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: acmeDNSTTL},
+			Txt: []string{txt},
+		})
+	}
+}
+
+func (s *AcmeDNSServiceImpl) soaRecord() dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: s.zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: acmeDNSTTL},
+		Ns:      dns.Fqdn(s.conf.NSName),
+		Mbox:    dns.Fqdn(s.conf.NSAdmin),
+		Serial:  uint32(time.Now().Unix()),
+		Refresh: 28800,
+		Retry:   7200,
+		Expire:  604800,
+		Minttl:  acmeDNSTTL,
+	}
+}
+
+func (s *AcmeDNSServiceImpl) aRecord(name string) dns.RR {
+	return &dns.A{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 3600},
+		A:   net.ParseIP(s.conf.IP),
+	}
+}
+
+type AcmeDNSRegisterReq struct {
+	AllowFrom []string `json:"allowfrom"`
+}
+
+// AcmeDNSRegisterResp 与 acme-dns 的 /register 响应一致
+type AcmeDNSRegisterResp struct {
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	FullDomain string   `json:"fulldomain"`
+	Subdomain  string   `json:"subdomain"`
+	AllowFrom  []string `json:"allowfrom"`
+}
+
+// Register 创建新的子域名及其凭据，密码只在注册时返回一次
+func (s *AcmeDNSServiceImpl) Register(ctx context.Context, req *AcmeDNSRegisterReq) (*AcmeDNSRegisterResp, error) {
+	if !s.conf.Enabled {
+		return nil, errors.New("acme-dns server is disabled")
+	}
+	allowFrom := make([]string, 0, len(req.AllowFrom))
+	for _, cidr := range req.AllowFrom {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, errors.Errorf("invalid allowfrom: %s", cidr)
+		}
+		allowFrom = append(allowFrom, cidr)
+	}
+
+	password, err := randomPassword()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to hash password")
+	}
+
+	registration := &model.AcmeDNSRegistration{Model: model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		Username: uuid.New().String(), PasswordHash: string(hash), Subdomain: uuid.New().String(), AllowFrom: allowFrom}
+	if err := s.db.Create(registration).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to create acme-dns registration")
+	}
+
+	return &AcmeDNSRegisterResp{
+		Username:   registration.Username,
+		Password:   password,
+		FullDomain: registration.Subdomain + "." + strings.TrimSuffix(s.zone, "."),
+		Subdomain:  registration.Subdomain,
+		AllowFrom:  allowFrom,
+	}, nil
+}
+
+type AcmeDNSUpdateReq struct {
+	Username  string `json:"-"`
+	Password  string `json:"-"`
+	ClientIP  string `json:"-"`
+	Subdomain string `json:"subdomain"`
+	TXT       string `json:"txt"`
+}
+
+// Update 校验凭据和来源地址后设置子域名的TXT记录
+func (s *AcmeDNSServiceImpl) Update(ctx context.Context, req *AcmeDNSUpdateReq) error {
+	var registration model.AcmeDNSRegistration
+	if err := s.db.First(&registration, "username = ?", req.Username).Error; err != nil {
+		return ErrAcmeDNSForbidden
+	}
+	if bcrypt.CompareHashAndPassword([]byte(registration.PasswordHash), []byte(req.Password)) != nil {
+		return ErrAcmeDNSForbidden
+	}
+	if registration.Subdomain != strings.ToLower(req.Subdomain) {
+		return ErrAcmeDNSForbidden
+	}
+	if !allowedFrom(registration.AllowFrom, req.ClientIP) {
+		return ErrAcmeDNSForbidden
+	}
+	if len(req.TXT) != acmeDNSTXTLen {
+		return errors.New("bad_txt")
+	}
+	return s.setTXT(ctx, registration.Subdomain, req.TXT)
+}
+
+// setTXT 保留上一次的值并追加新值，acme-dns 每个子域名最多两条TXT记录
+func (s *AcmeDNSServiceImpl) setTXT(ctx context.Context, subdomain, value string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var registration model.AcmeDNSRegistration
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&registration, "subdomain = ?", subdomain).Error; err != nil {
+			return errors.Wrap(err, "failure to get acme-dns registration")
+		}
+		txt := []string{value}
+		if n := len(registration.TXT); n > 0 && registration.TXT[n-1] != value {
+			txt = []string{registration.TXT[n-1], value}
+		}
+		updates := map[string]interface{}{
+			"txt":            pq.StringArray(txt),
+			"last_update_at": time.Now(),
+		}
+		if err := tx.Model(&model.AcmeDNSRegistration{}).Where("id = ?", registration.ID).Updates(updates).Error; err != nil {
+			return errors.Wrap(err, "failure to update acme-dns txt")
+		}
+		return nil
+	})
+}
+
+// NewProvider 返回直接写入内置DNS服务的 DNS-01 Provider。与 Update 一样校验注册的用户名和密码，
+// Provider 只能写入该注册的子域名
+func (s *AcmeDNSServiceImpl) NewProvider(ctx context.Context, username, password string) (challenge.Provider, error) {
+	if !s.conf.Enabled {
+		return nil, errors.New("acme-dns server is disabled")
+	}
+	var registration model.AcmeDNSRegistration
+	if err := s.db.First(&registration, "username = ?", username).Error; err != nil {
+		return nil, errors.New("acme-dns 注册不存在，请检查DNS提供商的用户名")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(registration.PasswordHash), []byte(password)) != nil {
+		return nil, errors.New("acme-dns 注册密码错误")
+	}
+	return &acmeDNSProvider{service: s, subdomain: registration.Subdomain}, nil
+}
+
+// acmeDNSProvider 通过 _acme-challenge 的CNAME找到对应的子域名并设置TXT记录，子域名必须是绑定的注册
+type acmeDNSProvider struct {
+	service   *AcmeDNSServiceImpl
+	subdomain string
+}
+
+func (p *acmeDNSProvider) Present(domain, token, keyAuth string) error {
+	info := dns01.GetChallengeInfo(domain, keyAuth)
+	fqdn := strings.ToLower(info.EffectiveFQDN)
+	subdomain := strings.TrimSuffix(fqdn, "."+p.service.zone)
+	if subdomain == fqdn || strings.Contains(subdomain, ".") {
+		return errors.Errorf("%s 未CNAME到 %s 下的子域名，请先添加CNAME记录", info.FQDN, p.service.zone)
+	}
+	if subdomain != p.subdomain {
+		return errors.Errorf("%s CNAME到的子域名 %s 不属于该DNS提供商的 acme-dns 注册", info.FQDN, subdomain)
+	}
+	return p.service.setTXT(context.Background(), subdomain, info.Value)
+}
+
+// CleanUp acme-dns 保留最近两次的TXT值，无需清理
+func (p *acmeDNSProvider) CleanUp(domain, token, keyAuth string) error {
+	return nil
+}
+
+func allowedFrom(cidrs []string, clientIP string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 30)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failure to generate password")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
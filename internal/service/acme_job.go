@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"easyacme/internal/model"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJobWorkers   = 2
+	defaultJobQueueSize = 100
+)
+
+// JobFunc 任务执行函数。ctx 在任务被取消或服务停止时取消，progress 用于上报执行进度，返回生成的证书ID
+type JobFunc func(ctx context.Context, progress JobProgress) (string, error)
+
+// JobProgress 任务进度上报
+type JobProgress interface {
+	// Step 上报当前步骤
+	Step(step string)
+	// DNSRecord 上报一条TXT记录的传播状态，同一记录的状态会被覆盖
+	DNSRecord(status *model.DNSRecordStatus)
+}
+
+// AcmeJobService 异步任务服务，任务持久化到数据库并由后台worker执行
+type AcmeJobService interface {
+	Start()
+	Stop()
+	SubmitJob(ctx context.Context, req *SubmitJobReq, fn JobFunc) (*model.AcmeJob, error)
+	GetJob(ctx context.Context, req *GetJobReq) (*model.AcmeJob, error)
+	CancelJob(ctx context.Context, req *CancelJobReq) error
+}
+
+type queuedJob struct {
+	id  string
+	ctx context.Context
+	fn  JobFunc
+}
+
+type AcmeJobServiceImpl struct {
+	db     *gorm.DB
+	logger *zap.Logger
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	queue   chan *queuedJob
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// NewAcmeJobService .
+func NewAcmeJobService(db *gorm.DB, logger *zap.Logger) AcmeJobService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AcmeJobServiceImpl{
+		db:      db,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+		queue:   make(chan *queuedJob, defaultJobQueueSize),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// Start 将上次运行遗留的未完成任务标记为失败，并启动worker
+func (s *AcmeJobServiceImpl) Start() {
+	now := time.Now()
+	err := s.db.Model(&model.AcmeJob{}).
+		Where("state IN ?", []model.JobState{model.JobQueued, model.JobRunning}).
+		Updates(map[string]interface{}{"state": model.JobFailed, "error": "服务重启，任务已中断", "finished_at": now}).Error
+	if err != nil {
+		s.logger.Error("failed to mark interrupted jobs", zap.Error(err))
+	}
+
+	for i := 0; i < defaultJobWorkers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	s.logger.Info("Job workers started", zap.Int("workers", defaultJobWorkers))
+}
+
+// Stop 取消所有进行中的任务并等待worker退出
+func (s *AcmeJobServiceImpl) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	s.logger.Info("Job workers stopped")
+}
+
+func (s *AcmeJobServiceImpl) worker() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case job := <-s.queue:
+			s.run(job)
+		}
+	}
+}
+
+// run 执行单个任务并记录结果
+func (s *AcmeJobServiceImpl) run(job *queuedJob) {
+	defer s.release(job.id)
+
+	if job.ctx.Err() != nil {
+		s.finish(job.id, model.JobCancelled, "", "任务已取消")
+		return
+	}
+
+	now := time.Now()
+	s.update(job.id, map[string]interface{}{"state": model.JobRunning, "started_at": now})
+
+	certID, err := job.fn(job.ctx, &jobProgress{id: job.id, s: s})
+	switch {
+	case job.ctx.Err() != nil:
+		s.finish(job.id, model.JobCancelled, certID, "任务已取消")
+	case err != nil:
+		s.logger.Error("job failed", zap.String("job_id", job.id), zap.Error(err))
+		s.finish(job.id, model.JobFailed, certID, err.Error())
+	default:
+		s.finish(job.id, model.JobSucceeded, certID, "")
+	}
+}
+
+// jobProgress 将任务进度写入任务记录
+type jobProgress struct {
+	id      string
+	s       *AcmeJobServiceImpl
+	mu      sync.Mutex
+	records model.DNSRecordStatuses
+}
+
+func (p *jobProgress) Step(step string) {
+	p.s.update(p.id, map[string]interface{}{"step": step})
+}
+
+func (p *jobProgress) DNSRecord(status *model.DNSRecordStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = p.records.Upsert(*status)
+	p.s.update(p.id, map[string]interface{}{"dns_records": p.records})
+}
+
+func (s *AcmeJobServiceImpl) finish(id string, state model.JobState, certID, errMsg string) {
+	s.update(id, map[string]interface{}{
+		"state":       state,
+		"cert_id":     certID,
+		"error":       errMsg,
+		"finished_at": time.Now(),
+	})
+}
+
+func (s *AcmeJobServiceImpl) update(id string, updates map[string]interface{}) {
+	if err := s.db.Model(&model.AcmeJob{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		s.logger.Error("failed to update job", zap.String("job_id", id), zap.Error(err))
+	}
+}
+
+func (s *AcmeJobServiceImpl) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+		delete(s.cancels, id)
+	}
+}
+
+type SubmitJobReq struct {
+	Type      model.JobType
+	Domains   []string
+	CreatedBy int
+}
+
+// SubmitJob 创建任务记录并放入队列，立即返回
+func (s *AcmeJobServiceImpl) SubmitJob(ctx context.Context, req *SubmitJobReq, fn JobFunc) (*model.AcmeJob, error) {
+	job := &model.AcmeJob{Model: model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		Type: req.Type, State: model.JobQueued, Domains: req.Domains, CreatedBy: req.CreatedBy}
+	if err := s.db.Create(job).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to create job")
+	}
+
+	jobCtx, cancel := context.WithCancel(s.ctx)
+	s.mu.Lock()
+	s.cancels[job.ID] = cancel
+	s.mu.Unlock()
+
+	select {
+	case s.queue <- &queuedJob{id: job.ID, ctx: jobCtx, fn: fn}:
+	default:
+		s.release(job.ID)
+		s.finish(job.ID, model.JobFailed, "", "任务队列已满")
+		return nil, errors.New("任务队列已满，请稍后重试")
+	}
+	return job, nil
+}
+
+type GetJobReq struct {
+	ID        string
+	CreatedBy int
+}
+
+// GetJob 查询任务，只能查询自己提交的任务
+func (s *AcmeJobServiceImpl) GetJob(ctx context.Context, req *GetJobReq) (*model.AcmeJob, error) {
+	var job model.AcmeJob
+	if err := s.db.First(&job, "id = ? AND created_by = ?", req.ID, req.CreatedBy).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to get job")
+	}
+	return &job, nil
+}
+
+type CancelJobReq struct {
+	ID        string
+	CreatedBy int
+}
+
+// CancelJob 取消自己提交的排队中或执行中的任务，取消信号会传递到正在进行的ACME请求
+func (s *AcmeJobServiceImpl) CancelJob(ctx context.Context, req *CancelJobReq) error {
+	if _, err := s.GetJob(ctx, &GetJobReq{ID: req.ID, CreatedBy: req.CreatedBy}); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	cancel, ok := s.cancels[req.ID]
+	s.mu.Unlock()
+	if !ok {
+		return errors.New("任务不存在或已结束")
+	}
+	cancel()
+
+	// 排队中的任务直接标记为已取消，执行中的任务由worker在退出时标记
+	err := s.db.Model(&model.AcmeJob{}).Where("id = ? AND state = ?", req.ID, model.JobQueued).
+		Updates(map[string]interface{}{"state": model.JobCancelled, "error": "任务已取消", "finished_at": time.Now()}).Error
+	if err != nil {
+		return errors.Wrap(err, "failure to cancel job")
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/lego"
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+)
+
+// signedPost 以账户密钥签名并POST到ACME接口，用于lego未提供的请求（如keyChange、清空联系人），返回响应体
+func signedPost(ctx context.Context, conf *lego.Config, directory acme.Directory, key crypto.PrivateKey, kid, url string, payload []byte) ([]byte, error) {
+	nonces := &nonceSource{ctx: ctx, client: conf.HTTPClient, url: directory.NewNonceURL, userAgent: conf.UserAgent}
+	signed, err := signJWS(key, kid, url, payload, nonces)
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to sign jws")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString(signed.FullSerialize()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/jose+json")
+	httpReq.Header.Set("User-Agent", conf.UserAgent)
+	resp, err := conf.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		problem := &acme.ProblemDetails{}
+		if err := json.Unmarshal(body, problem); err != nil || problem.Type == "" {
+			return nil, fmt.Errorf("acme: error: %d :: %s", resp.StatusCode, string(body))
+		}
+		problem.HTTPStatus = resp.StatusCode
+		problem.Method = http.MethodPost
+		problem.URL = url
+		return nil, problem
+	}
+	return body, nil
+}
+
+// signJWS 使用给定私钥签名，kid 为空时在头部内嵌公钥
+func signJWS(key crypto.PrivateKey, kid, url string, payload []byte, nonces jose.NonceSource) (*jose.JSONWebSignature, error) {
+	var alg jose.SignatureAlgorithm
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		alg = jose.RS256
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			alg = jose.ES256
+		case elliptic.P384():
+			alg = jose.ES384
+		}
+	}
+	if alg == "" {
+		return nil, errors.New("unsupported account key")
+	}
+
+	options := &jose.SignerOptions{
+		NonceSource:  nonces,
+		ExtraHeaders: map[jose.HeaderKey]interface{}{"url": url},
+		EmbedJWK:     kid == "",
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: jose.JSONWebKey{Key: key, KeyID: kid}}, options)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Sign(payload)
+}
+
+// nonceSource 从CA的newNonce接口获取 Replay-Nonce
+type nonceSource struct {
+	ctx       context.Context
+	client    *http.Client
+	url       string
+	userAgent string
+}
+
+func (n *nonceSource) Nonce() (string, error) {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodHead, n.url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", n.userAgent)
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failure to get nonce")
+	}
+	defer resp.Body.Close()
+
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", errors.New("server did not respond with a proper nonce header")
+	}
+	return nonce, nil
+}
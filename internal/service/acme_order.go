@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"easyacme/internal/model"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// pendingOrderStatuses 仍可继续完成验证和签发的订单状态
+var pendingOrderStatuses = []string{"pending", "ready", "processing"}
+
+type AcmeOrderService interface {
+	CreateOrder(ctx context.Context, order *model.AcmeOrder) error
+	GetOrder(ctx context.Context, req *GetOrderReq) (*model.AcmeOrder, error)
+	FindPendingOrder(ctx context.Context, req *FindPendingOrderReq) (*model.AcmeOrder, error)
+	ListPendingOrders(ctx context.Context, req *ListPendingOrderReq) (*ListOrderResp, error)
+	UpdateOrderStatus(ctx context.Context, req *UpdateOrderStatusReq) error
+}
+
+type AcmeOrderServiceImpl struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewAcmeOrderService .
+func NewAcmeOrderService(db *gorm.DB, logger *zap.Logger) AcmeOrderService {
+	return &AcmeOrderServiceImpl{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateOrder 保存订单及其授权信息
+func (s *AcmeOrderServiceImpl) CreateOrder(ctx context.Context, order *model.AcmeOrder) error {
+	if err := s.db.Create(order).Error; err != nil {
+		return errors.Wrap(err, "failure to create acme order")
+	}
+	return nil
+}
+
+type GetOrderReq struct {
+	ID        string
+	CreatedBy int
+}
+
+func (s *AcmeOrderServiceImpl) GetOrder(ctx context.Context, req *GetOrderReq) (*model.AcmeOrder, error) {
+	var order model.AcmeOrder
+	if err := s.db.Preload("Authorizations").First(&order, "id = ? AND created_by = ?", req.ID, req.CreatedBy).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to get acme order")
+	}
+	return &order, nil
+}
+
+type FindPendingOrderReq struct {
+	AccountID string
+	KeyType   certcrypto.KeyType
+	Domains   []string
+	CreatedBy int
+}
+
+// FindPendingOrder 按账户、密钥类型和域名查找当前用户最近一个未完成且未过期的订单
+func (s *AcmeOrderServiceImpl) FindPendingOrder(ctx context.Context, req *FindPendingOrderReq) (*model.AcmeOrder, error) {
+	var order model.AcmeOrder
+	err := s.db.Preload("Authorizations").
+		Where("account_id = ? AND key_type = ? AND domains = ?", req.AccountID, req.KeyType, pq.StringArray(req.Domains)).
+		Where("created_by = ?", req.CreatedBy).
+		Where("status IN ? AND (expires_at IS NULL OR expires_at > NOW())", pendingOrderStatuses).
+		Order("created_at desc").
+		First(&order).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to find pending acme order")
+	}
+	return &order, nil
+}
+
+type ListPendingOrderReq struct {
+	Page      int `form:"page"`
+	PageSize  int `form:"page_size"`
+	CreatedBy int `form:"-"`
+}
+
+type ListOrderResp struct {
+	Total int64             `json:"total"`
+	List  []model.AcmeOrder `json:"data"`
+}
+
+// ListPendingOrders 查询指定用户未完成且未过期的手动订单
+func (s *AcmeOrderServiceImpl) ListPendingOrders(ctx context.Context, req *ListPendingOrderReq) (*ListOrderResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 10
+	}
+
+	var total int64
+	var orders []model.AcmeOrder
+
+	query := s.db.Model(&model.AcmeOrder{}).
+		Where("created_by = ?", req.CreatedBy).
+		Where("status IN ? AND (expires_at IS NULL OR expires_at > NOW())", pendingOrderStatuses)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to count acme orders")
+	}
+
+	offset := (req.Page - 1) * req.PageSize
+	if err := query.Preload("Authorizations").Offset(offset).Limit(req.PageSize).Order("created_at desc").Find(&orders).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to query acme orders")
+	}
+	return &ListOrderResp{Total: total, List: orders}, nil
+}
+
+type UpdateOrderStatusReq struct {
+	ID     string
+	Status string
+	CertID string
+}
+
+func (s *AcmeOrderServiceImpl) UpdateOrderStatus(ctx context.Context, req *UpdateOrderStatusReq) error {
+	updates := map[string]interface{}{
+		"status": req.Status,
+	}
+	if req.CertID != "" {
+		updates["cert_id"] = req.CertID
+	}
+	if err := s.db.Model(&model.AcmeOrder{}).Where("id = ?", req.ID).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "failure to update acme order")
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"easyacme/internal/model"
+	"fmt"
+	"github.com/miekg/dns"
+	"go.uber.org/zap"
+	"net"
+	"slices"
+	"strings"
+)
+
+// knownCAATags 已知的CAA属性，关键标志位置位的未知属性会禁止签发 (RFC 8659 4.5)
+var knownCAATags = []string{"issue", "issuewild", "iodef", "contactemail", "contactphone", "issuemail", "issuevmc"}
+
+type CheckCAAReq struct {
+	Domains []string
+	Solver  model.ChallengeSolver
+	// CAAIdentities CA目录元数据中的 caaIdentities，即CAA记录中代表该CA的域名
+	CAAIdentities []string
+	AccountURI    string
+}
+
+// CheckCAA 下单前检查每个域名的CAA记录是否允许账户所在CA签发，包括 accounturi 和 validationmethods 参数
+func (s *AcmeCertServiceImpl) CheckCAA(ctx context.Context, req *CheckCAAReq) error {
+	if s.conf.Challenge.DisableCAACheck {
+		return nil
+	}
+	if len(req.CAAIdentities) == 0 {
+		s.logger.Info("CA does not publish caaIdentities, skip CAA check")
+		return nil
+	}
+
+	method := validationMethod(req.Solver)
+	resolvers := s.propagationService.Resolvers()
+	for _, domain := range req.Domains {
+		if net.ParseIP(domain) != nil {
+			continue // CAA不适用于IP标识
+		}
+		wildcard := strings.HasPrefix(domain, "*.")
+		name := strings.TrimPrefix(domain, "*.")
+
+		owner, records, err := lookupRelevantCAA(ctx, resolvers, name)
+		if err != nil {
+			// 查询失败时不阻止下单，由CA在验证时做最终判断
+			s.logger.Warn("failure to lookup CAA", zap.String("domain", domain), zap.Error(err))
+			continue
+		}
+		if len(records) == 0 {
+			continue
+		}
+		if err := evaluateCAA(records, wildcard, req.CAAIdentities, req.AccountURI, method); err != nil {
+			return fmt.Errorf("域名 %s 的CAA记录（位于 %s）不允许当前CA签发: %w", domain, strings.TrimSuffix(owner, "."), err)
+		}
+	}
+	return nil
+}
+
+// validationMethod 验证方式对应的ACME验证方法名
+func validationMethod(solver model.ChallengeSolver) string {
+	switch solver {
+	case model.SolverHTTP01Standalone, model.SolverHTTP01Webroot:
+		return "http-01"
+	case model.SolverTLSALPN01:
+		return "tls-alpn-01"
+	default:
+		return "dns-01"
+	}
+}
+
+// lookupRelevantCAA 从域名开始逐级向上查找，返回第一个非空的CAA记录集及其所在域名 (RFC 8659 3)
+func lookupRelevantCAA(ctx context.Context, resolvers []string, name string) (string, []*dns.CAA, error) {
+	labels := dns.SplitDomainName(name)
+	for i := range labels {
+		owner := dns.Fqdn(strings.Join(labels[i:], "."))
+		records, err := queryCAA(ctx, resolvers, owner)
+		if err != nil {
+			return owner, nil, err
+		}
+		if len(records) > 0 {
+			return owner, records, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// queryCAA 通过递归DNS查询CAA记录，递归DNS会跟随CNAME返回目标的记录
+func queryCAA(ctx context.Context, resolvers []string, owner string) ([]*dns.CAA, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(owner, dns.TypeCAA)
+	m.SetEdns0(4096, false)
+
+	var lastErr error
+	for _, resolver := range resolvers {
+		in, err := exchange(ctx, m, resolver)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if in.Rcode != dns.RcodeSuccess && in.Rcode != dns.RcodeNameError {
+			lastErr = fmt.Errorf("%s returned %s for %s", resolver, dns.RcodeToString[in.Rcode], owner)
+			continue
+		}
+		var records []*dns.CAA
+		for _, rr := range in.Answer {
+			if caa, ok := rr.(*dns.CAA); ok {
+				records = append(records, caa)
+			}
+		}
+		return records, nil
+	}
+	return nil, lastErr
+}
+
+// evaluateCAA 判断CAA记录集是否允许签发，不允许时返回每条相关记录被拒绝的原因
+func evaluateCAA(records []*dns.CAA, wildcard bool, identities []string, accountURI, method string) error {
+	for _, record := range records {
+		if record.Flag&128 != 0 && !slices.Contains(knownCAATags, strings.ToLower(record.Tag)) {
+			return fmt.Errorf("存在未知的关键CAA属性 %q", record.Tag)
+		}
+	}
+
+	tag := "issue"
+	if wildcard && slices.ContainsFunc(records, func(r *dns.CAA) bool { return strings.EqualFold(r.Tag, "issuewild") }) {
+		tag = "issuewild"
+	}
+
+	var reasons []string
+	for _, record := range records {
+		if !strings.EqualFold(record.Tag, tag) {
+			continue
+		}
+		issuer, params := parseCAAValue(record.Value)
+		switch {
+		case issuer == "":
+			reasons = append(reasons, fmt.Sprintf("%s %q 禁止任何CA签发", tag, record.Value))
+		case !slices.ContainsFunc(identities, func(id string) bool { return strings.EqualFold(id, issuer) }):
+			reasons = append(reasons, fmt.Sprintf("%s %q 的CA不是 %s", tag, record.Value, strings.Join(identities, "/")))
+		case params["accounturi"] != "" && params["accounturi"] != accountURI:
+			reasons = append(reasons, fmt.Sprintf("%s %q 限制了 accounturi，当前账户为 %s", tag, record.Value, accountURI))
+		case params["validationmethods"] != "" && !slices.Contains(strings.Split(params["validationmethods"], ","), method):
+			reasons = append(reasons, fmt.Sprintf("%s %q 不允许 %s 验证", tag, record.Value, method))
+		default:
+			return nil
+		}
+	}
+	if len(reasons) == 0 {
+		// 没有对应的 issue/issuewild 属性，不限制签发
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(reasons, "; "))
+}
+
+// parseCAAValue 解析 issue/issuewild 属性值，如 "letsencrypt.org; accounturi=https://...; validationmethods=dns-01"
+func parseCAAValue(value string) (string, map[string]string) {
+	parts := strings.Split(value, ";")
+	issuer := strings.TrimSpace(parts[0])
+	params := make(map[string]string)
+	for _, part := range parts[1:] {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(val)
+	}
+	return issuer, params
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"github.com/miekg/dns"
+	"reflect"
+	"testing"
+)
+
+func caa(flag uint8, tag, value string) *dns.CAA {
+	return &dns.CAA{Flag: flag, Tag: tag, Value: value}
+}
+
+func TestParseCAAValue(t *testing.T) {
+	tests := []struct {
+		value  string
+		issuer string
+		params map[string]string
+	}{
+		{"letsencrypt.org", "letsencrypt.org", map[string]string{}},
+		{";", "", map[string]string{}},
+		{"", "", map[string]string{}},
+		{" letsencrypt.org ; accounturi=https://acme.example/acct/1", "letsencrypt.org",
+			map[string]string{"accounturi": "https://acme.example/acct/1"}},
+		{"letsencrypt.org; ValidationMethods = dns-01,http-01", "letsencrypt.org",
+			map[string]string{"validationmethods": "dns-01,http-01"}},
+		{"letsencrypt.org; broken; accounturi=a", "letsencrypt.org", map[string]string{"accounturi": "a"}},
+	}
+	for _, tt := range tests {
+		issuer, params := parseCAAValue(tt.value)
+		if issuer != tt.issuer || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("parseCAAValue(%q) = %q, %v; want %q, %v", tt.value, issuer, params, tt.issuer, tt.params)
+		}
+	}
+}
+
+func TestEvaluateCAA(t *testing.T) {
+	identities := []string{"letsencrypt.org"}
+	const account = "https://acme.example/acct/1"
+	tests := []struct {
+		name     string
+		records  []*dns.CAA
+		wildcard bool
+		method   string
+		allowed  bool
+	}{
+		{"issuer matches", []*dns.CAA{caa(0, "issue", "letsencrypt.org")}, false, "dns-01", true},
+		{"issuer case insensitive", []*dns.CAA{caa(0, "ISSUE", "LetsEncrypt.org")}, false, "dns-01", true},
+		{"other issuer", []*dns.CAA{caa(0, "issue", "pki.goog")}, false, "dns-01", false},
+		{"any of several issuers", []*dns.CAA{caa(0, "issue", "pki.goog"), caa(0, "issue", "letsencrypt.org")}, false, "dns-01", true},
+		{"empty issuer forbids", []*dns.CAA{caa(0, "issue", ";")}, false, "dns-01", false},
+		{"no issue tag", []*dns.CAA{caa(0, "iodef", "mailto:a@example.com")}, false, "dns-01", true},
+		{"unknown critical tag", []*dns.CAA{caa(128, "future", "x"), caa(0, "issue", "letsencrypt.org")}, false, "dns-01", false},
+		{"unknown non-critical tag", []*dns.CAA{caa(0, "future", "x"), caa(0, "issue", "letsencrypt.org")}, false, "dns-01", true},
+		{"known critical tag", []*dns.CAA{caa(128, "issue", "letsencrypt.org")}, false, "dns-01", true},
+		{"wildcard falls back to issue", []*dns.CAA{caa(0, "issue", "letsencrypt.org")}, true, "dns-01", true},
+		{"issuewild overrides issue", []*dns.CAA{caa(0, "issue", "letsencrypt.org"), caa(0, "issuewild", ";")}, true, "dns-01", false},
+		{"issuewild ignored for non-wildcard", []*dns.CAA{caa(0, "issue", "letsencrypt.org"), caa(0, "issuewild", ";")}, false, "dns-01", true},
+		{"accounturi matches", []*dns.CAA{caa(0, "issue", "letsencrypt.org; accounturi="+account)}, false, "dns-01", true},
+		{"accounturi differs", []*dns.CAA{caa(0, "issue", "letsencrypt.org; accounturi=https://acme.example/acct/2")}, false, "dns-01", false},
+		{"validationmethods allows", []*dns.CAA{caa(0, "issue", "letsencrypt.org; validationmethods=http-01,dns-01")}, false, "dns-01", true},
+		{"validationmethods forbids", []*dns.CAA{caa(0, "issue", "letsencrypt.org; validationmethods=http-01")}, false, "dns-01", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := evaluateCAA(tt.records, tt.wildcard, identities, account, tt.method)
+			if (err == nil) != tt.allowed {
+				t.Errorf("evaluateCAA() error = %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+}
@@ -3,6 +3,15 @@ package service
 import (
 	"context"
 	"easyacme/internal/model"
+	"fmt"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/providers/dns/alidns"
+	"github.com/go-acme/lego/v4/providers/dns/baiducloud"
+	"github.com/go-acme/lego/v4/providers/dns/cloudflare"
+	"github.com/go-acme/lego/v4/providers/dns/godaddy"
+	"github.com/go-acme/lego/v4/providers/dns/huaweicloud"
+	"github.com/go-acme/lego/v4/providers/dns/route53"
+	"github.com/go-acme/lego/v4/providers/dns/tencentcloud"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	//dnspod "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/dnspod/v20210323"
@@ -24,11 +33,14 @@ type DNSService interface {
 	BatchDeleteDNSProvers(ctx context.Context, req *BatchDeleteDNSProviderReq) error
 	GetDNSProviderStats(ctx context.Context) ([]DNSProviderStat, error)
 	GetDNSProviderSecrets(ctx context.Context, req *GetDNSProviderSecretsReq) (*DNSProviderSecrets, error)
+	CreateLegoDNSProvider(ctx context.Context, req *GetDNSProviderReq) (challenge.Provider, error)
+	CreateRoutedDNSProvider(ctx context.Context, req *CreateRoutedDNSProviderReq) (challenge.Provider, error)
 }
 
 type DNSServiceImpl struct {
-	db     *gorm.DB
-	logger *zap.Logger
+	db             *gorm.DB
+	logger         *zap.Logger
+	acmeDNSService AcmeDNSService
 }
 
 type ListDNSProviderReq struct {
@@ -111,10 +123,11 @@ func (d *DNSServiceImpl) CreateDNSProvider(ctx context.Context, req *CreateDNSPr
 }
 
 // NewDNSService .
-func NewDNSService(db *gorm.DB, logger *zap.Logger) DNSService {
+func NewDNSService(db *gorm.DB, logger *zap.Logger, acmeDNSService AcmeDNSService) DNSService {
 	return &DNSServiceImpl{
-		db:     db,
-		logger: logger,
+		db:             db,
+		logger:         logger,
+		acmeDNSService: acmeDNSService,
 	}
 }
 
@@ -214,3 +227,57 @@ func (d *DNSServiceImpl) GetDNSProviderStats(ctx context.Context) ([]DNSProvider
 	}
 	return stats, nil
 }
+
+// CreateLegoDNSProvider 根据DNS提供商记录（含密钥）创建lego的DNS-01 Provider
+func (d *DNSServiceImpl) CreateLegoDNSProvider(ctx context.Context, req *GetDNSProviderReq) (challenge.Provider, error) {
+	var provider model.DNSProvider
+	if err := d.db.First(&provider, "id = ?", req.ID).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to get dns provider")
+	}
+	if provider.Type == model.DNSTypeAcmeDNS {
+		return d.acmeDNSService.NewProvider(ctx, provider.SecretId, provider.SecretKey)
+	}
+	return newLegoDNSProvider(&provider)
+}
+
+// newLegoDNSProvider is a factory function that creates a DNS provider instance.
+func newLegoDNSProvider(provider *model.DNSProvider) (challenge.Provider, error) {
+	switch provider.Type {
+	case model.DNSTypeTencentCloud:
+		cf := tencentcloud.NewDefaultConfig()
+		cf.SecretID = provider.SecretId
+		cf.SecretKey = provider.SecretKey
+		return tencentcloud.NewDNSProviderConfig(cf)
+	case model.DNSTypeAliyun:
+		cf := alidns.NewDefaultConfig()
+		cf.APIKey = provider.SecretId
+		cf.SecretKey = provider.SecretKey
+		return alidns.NewDNSProviderConfig(cf)
+	case model.DNSTypeCloudflare:
+		cf := cloudflare.NewDefaultConfig()
+		cf.AuthToken = provider.SecretKey
+		return cloudflare.NewDNSProviderConfig(cf)
+	case model.DNSTypeGoDaddy:
+		cf := godaddy.NewDefaultConfig()
+		cf.APIKey = provider.SecretId
+		cf.APISecret = provider.SecretKey
+		return godaddy.NewDNSProviderConfig(cf)
+	case model.DNSTypeBaiduCloud:
+		cf := baiducloud.NewDefaultConfig()
+		cf.AccessKeyID = provider.SecretId
+		cf.SecretAccessKey = provider.SecretKey
+		return baiducloud.NewDNSProviderConfig(cf)
+	case model.DNSTypeHuaweiCloud:
+		cf := huaweicloud.NewDefaultConfig()
+		cf.AccessKeyID = provider.SecretId
+		cf.SecretAccessKey = provider.SecretKey
+		return huaweicloud.NewDNSProviderConfig(cf)
+	case model.DNSTypeRoute53:
+		cf := route53.NewDefaultConfig()
+		cf.AccessKeyID = provider.SecretId
+		cf.SecretAccessKey = provider.SecretKey
+		return route53.NewDNSProviderConfig(cf)
+	default:
+		return nil, fmt.Errorf("unsupported DNS provider type: %s", provider.Type)
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"easyacme/internal/model"
+	"fmt"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// ValidateDNSRoutes 校验路由表，并确认每个域名都能匹配到路由或使用默认DNS提供商
+func ValidateDNSRoutes(routes model.DNSRoutes, defaultProviderID string, domains []string) error {
+	for _, route := range routes {
+		if route.Zone == "" || route.DNSProviderID == "" {
+			return errors.New("DNS路由需要指定区域和DNS提供商")
+		}
+	}
+	for _, domain := range domains {
+		if _, ok := routes.Match(domain); !ok && defaultProviderID == "" {
+			return fmt.Errorf("域名 %s 没有匹配的DNS提供商", domain)
+		}
+	}
+	return nil
+}
+
+type CreateRoutedDNSProviderReq struct {
+	// DefaultProviderID 未匹配任何路由的域名使用的DNS提供商
+	DefaultProviderID string
+	Routes            model.DNSRoutes
+	Domains           []string
+}
+
+// CreateRoutedDNSProvider 创建按域名所在区域分发到不同DNS提供商的 Provider，没有路由时等同于 CreateLegoDNSProvider
+func (d *DNSServiceImpl) CreateRoutedDNSProvider(ctx context.Context, req *CreateRoutedDNSProviderReq) (challenge.Provider, error) {
+	if len(req.Routes) == 0 {
+		return d.CreateLegoDNSProvider(ctx, &GetDNSProviderReq{ID: req.DefaultProviderID})
+	}
+	if err := ValidateDNSRoutes(req.Routes, req.DefaultProviderID, req.Domains); err != nil {
+		return nil, err
+	}
+
+	routed := &routedDNSProvider{routes: req.Routes, defaultID: req.DefaultProviderID, providers: make(map[string]challenge.Provider)}
+	for _, domain := range req.Domains {
+		id := routed.providerID(domain)
+		if _, ok := routed.providers[id]; ok {
+			continue
+		}
+		provider, err := d.CreateLegoDNSProvider(ctx, &GetDNSProviderReq{ID: id})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failure to create dns provider for %s", domain)
+		}
+		routed.providers[id] = provider
+	}
+	return routed, nil
+}
+
+// routedDNSProvider 组合多个DNS提供商，按域名所在区域分发 Present/CleanUp
+type routedDNSProvider struct {
+	routes    model.DNSRoutes
+	defaultID string
+	providers map[string]challenge.Provider
+}
+
+func (r *routedDNSProvider) providerID(domain string) string {
+	if route, ok := r.routes.Match(domain); ok {
+		return route.DNSProviderID
+	}
+	return r.defaultID
+}
+
+func (r *routedDNSProvider) provider(domain string) (challenge.Provider, error) {
+	provider, ok := r.providers[r.providerID(domain)]
+	if !ok {
+		return nil, fmt.Errorf("域名 %s 没有匹配的DNS提供商", domain)
+	}
+	return provider, nil
+}
+
+func (r *routedDNSProvider) Present(domain, token, keyAuth string) error {
+	provider, err := r.provider(domain)
+	if err != nil {
+		return err
+	}
+	return provider.Present(domain, token, keyAuth)
+}
+
+func (r *routedDNSProvider) CleanUp(domain, token, keyAuth string) error {
+	provider, err := r.provider(domain)
+	if err != nil {
+		return err
+	}
+	return provider.CleanUp(domain, token, keyAuth)
+}
+
+// Timeout 取各DNS提供商中最长的传播等待时间和轮询间隔
+func (r *routedDNSProvider) Timeout() (time.Duration, time.Duration) {
+	timeout, interval := dns01.DefaultPropagationTimeout, dns01.DefaultPollingInterval
+	for _, provider := range r.providers {
+		p, ok := provider.(challenge.ProviderTimeout)
+		if !ok {
+			continue
+		}
+		t, i := p.Timeout()
+		timeout, interval = max(timeout, t), max(interval, i)
+	}
+	return timeout, interval
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"easyacme/internal/model"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DomainService 受管域名
+type DomainService interface {
+	CreateDomain(ctx context.Context, req *CreateDomainReq) (*model.Domain, error)
+	GetDomains(ctx context.Context, req *ListDomainReq) (*ListDomainResp, error)
+	GetDomain(ctx context.Context, req *GetDomainReq) (*model.Domain, error)
+	UpdateDomain(ctx context.Context, req *UpdateDomainReq) error
+	DeleteDomain(ctx context.Context, req *DeleteDomainReq) error
+	ResolveIssueDefaults(ctx context.Context, req *ResolveIssueDefaultsReq) (*IssueDefaults, error)
+}
+
+type DomainServiceImpl struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewDomainService .
+func NewDomainService(db *gorm.DB, logger *zap.Logger) DomainService {
+	return &DomainServiceImpl{
+		db:     db,
+		logger: logger,
+	}
+}
+
+type CreateDomainReq struct {
+	Name          string `json:"name" binding:"required"`
+	DNSProviderID string `json:"dns_provider_id"`
+	AccountID     string `json:"account_id"`
+	OwnerID       int    `json:"owner_id"` // 为空时为当前用户
+	Notes         string `json:"notes"`
+}
+
+func (d *DomainServiceImpl) CreateDomain(ctx context.Context, req *CreateDomainReq) (*model.Domain, error) {
+	name := model.NormalizeDomainName(req.Name)
+	if err := d.validate(name, req.DNSProviderID, req.AccountID); err != nil {
+		return nil, err
+	}
+
+	domain := &model.Domain{Model: model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		Name: name, DNSProviderID: req.DNSProviderID, AccountID: req.AccountID, OwnerID: req.OwnerID, Notes: req.Notes}
+	if err := d.db.Create(domain).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to create domain")
+	}
+	return domain, nil
+}
+
+// validate 校验域名格式以及默认DNS提供商和账户是否存在
+func (d *DomainServiceImpl) validate(name, dnsProviderID, accountID string) error {
+	if name == "" || strings.Contains(name, "*") || strings.Contains(name, " ") {
+		return errors.New("invalid domain name: " + name)
+	}
+	if dnsProviderID != "" {
+		if err := d.db.Select("id").First(&model.DNSProvider{}, "id = ?", dnsProviderID).Error; err != nil {
+			return errors.Wrap(err, "failure to get dns provider")
+		}
+	}
+	if accountID != "" {
+		if err := d.db.Select("id").First(&model.AcmeAccount{}, "id = ?", accountID).Error; err != nil {
+			return errors.Wrap(err, "failure to get acme account")
+		}
+	}
+	return nil
+}
+
+type ListDomainReq struct {
+	Page          int    `form:"page"`
+	PageSize      int    `form:"page_size"`
+	Name          string `form:"name"`
+	DNSProviderID string `form:"dns_provider_id"`
+	OwnerID       int    `form:"owner_id"`
+}
+
+type ListDomainResp struct {
+	Total int64          `json:"total"`
+	List  []model.Domain `json:"data"`
+}
+
+func (d *DomainServiceImpl) GetDomains(ctx context.Context, req *ListDomainReq) (*ListDomainResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 10
+	}
+
+	query := d.db.Model(&model.Domain{})
+	if req.Name != "" {
+		query = query.Where("name LIKE ?", "%"+model.NormalizeDomainName(req.Name)+"%")
+	}
+	if req.DNSProviderID != "" {
+		query = query.Where("dns_provider_id = ?", req.DNSProviderID)
+	}
+	if req.OwnerID > 0 {
+		query = query.Where("owner_id = ?", req.OwnerID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to count domains")
+	}
+	var domains []model.Domain
+	offset := (req.Page - 1) * req.PageSize
+	if err := query.Offset(offset).Limit(req.PageSize).Order("name asc").Find(&domains).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to query domains")
+	}
+	return &ListDomainResp{Total: total, List: domains}, nil
+}
+
+type GetDomainReq struct {
+	ID string
+}
+
+// GetDomain 查询受管域名及覆盖该域名或其子域名的证书
+func (d *DomainServiceImpl) GetDomain(ctx context.Context, req *GetDomainReq) (*model.Domain, error) {
+	var domain model.Domain
+	if err := d.db.First(&domain, "id = ?", req.ID).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to get domain")
+	}
+
+	var certs []model.AcmeCert
+	err := d.db.Model(&model.AcmeCert{}).
+		Select("id", "domains", "key_type", "cert_status", "issued_at", "validity_days", "auto_renew").
+		Where("EXISTS (SELECT 1 FROM unnest(domains) AS d WHERE d = ? OR d LIKE ?)", domain.Name, "%."+domain.Name).
+		Order("issued_at desc").
+		Find(&certs).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "failure to query domain certs")
+	}
+	domain.Certificates = make([]model.DomainCert, 0, len(certs))
+	for _, cert := range certs {
+		domain.Certificates = append(domain.Certificates, model.DomainCert{ID: cert.ID, Domains: cert.Domains, KeyType: string(cert.KeyType),
+			CertStatus: cert.CertStatus, IssuedAt: cert.IssuedAt, ValidityDays: cert.ValidityDays, AutoRenew: cert.AutoRenew})
+	}
+	return &domain, nil
+}
+
+type UpdateDomainReq struct {
+	ID            string `json:"-"`
+	DNSProviderID string `json:"dns_provider_id"`
+	AccountID     string `json:"account_id"`
+	OwnerID       int    `json:"owner_id"`
+	Notes         string `json:"notes"`
+}
+
+// UpdateDomain 修改默认DNS提供商、账户、负责人和备注，域名本身不可修改
+func (d *DomainServiceImpl) UpdateDomain(ctx context.Context, req *UpdateDomainReq) error {
+	var domain model.Domain
+	if err := d.db.First(&domain, "id = ?", req.ID).Error; err != nil {
+		return errors.Wrap(err, "failure to get domain")
+	}
+	if err := d.validate(domain.Name, req.DNSProviderID, req.AccountID); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"dns_provider_id": req.DNSProviderID,
+		"account_id":      req.AccountID,
+		"owner_id":        req.OwnerID,
+		"notes":           req.Notes,
+	}
+	if err := d.db.Model(&model.Domain{}).Where("id = ?", req.ID).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "failure to update domain")
+	}
+	return nil
+}
+
+type DeleteDomainReq struct {
+	ID string
+}
+
+func (d *DomainServiceImpl) DeleteDomain(ctx context.Context, req *DeleteDomainReq) error {
+	if err := d.db.Where("id = ?", req.ID).Delete(&model.Domain{}).Error; err != nil {
+		return errors.Wrap(err, "failure to delete domain")
+	}
+	return nil
+}
+
+type ResolveIssueDefaultsReq struct {
+	Domains []string
+}
+
+// IssueDefaults 根据受管域名得到的签发参数
+type IssueDefaults struct {
+	AccountID     string
+	DNSProviderID string
+	DNSRoutes     model.DNSRoutes // 域名分属不同DNS提供商时按区域路由
+}
+
+// ResolveIssueDefaults 为每个域名找到最长匹配的受管域名，得出签发使用的账户和DNS提供商。
+// 所有域名都没有默认DNS提供商时返回空，即手动验证
+func (d *DomainServiceImpl) ResolveIssueDefaults(ctx context.Context, req *ResolveIssueDefaultsReq) (*IssueDefaults, error) {
+	var candidates []string
+	for _, name := range req.Domains {
+		candidates = append(candidates, parentNames(name)...)
+	}
+	var domains []model.Domain
+	if err := d.db.Where("name IN ?", candidates).Find(&domains).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to query domains")
+	}
+	byName := make(map[string]model.Domain, len(domains))
+	for _, domain := range domains {
+		byName[domain.Name] = domain
+	}
+
+	defaults := &IssueDefaults{}
+	providers := make(map[string]struct{})
+	var routes model.DNSRoutes
+	var noProvider []string
+	for _, name := range req.Domains {
+		var matched *model.Domain
+		for _, parent := range parentNames(name) {
+			if domain, ok := byName[parent]; ok {
+				matched = &domain
+				break
+			}
+		}
+		if matched == nil {
+			return nil, fmt.Errorf("域名 %s 不属于任何受管域名", name)
+		}
+
+		if matched.AccountID != "" {
+			if defaults.AccountID != "" && defaults.AccountID != matched.AccountID {
+				return nil, fmt.Errorf("域名 %s 的默认ACME账户与其他域名不同，请指定账户", name)
+			}
+			defaults.AccountID = matched.AccountID
+		}
+		if matched.DNSProviderID == "" {
+			noProvider = append(noProvider, name)
+			continue
+		}
+		providers[matched.DNSProviderID] = struct{}{}
+		if !slices.ContainsFunc(routes, func(route model.DNSRoute) bool { return route.Zone == matched.Name }) {
+			routes = append(routes, model.DNSRoute{Zone: matched.Name, DNSProviderID: matched.DNSProviderID})
+		}
+	}
+	if defaults.AccountID == "" {
+		return nil, errors.New("受管域名没有默认ACME账户，请指定账户")
+	}
+
+	switch {
+	case len(providers) == 0:
+	case len(noProvider) > 0:
+		return nil, fmt.Errorf("域名 %s 没有默认DNS提供商", strings.Join(noProvider, ", "))
+	case len(providers) == 1:
+		defaults.DNSProviderID = routes[0].DNSProviderID
+	default:
+		defaults.DNSRoutes = routes
+	}
+	return defaults, nil
+}
+
+// parentNames 返回域名本身及其所有上级域名，由长到短排列，通配符按其基础域名处理
+func parentNames(name string) []string {
+	name = model.NormalizeDomainName(strings.TrimPrefix(name, "*."))
+	labels := strings.Split(name, ".")
+	names := make([]string, 0, len(labels))
+	for i := range labels {
+		names = append(names, strings.Join(labels[i:], "."))
+	}
+	return names
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"easyacme/internal/model"
+	"encoding/pem"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/pkcs12"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ImportExternalCertReq struct {
+	Data     []byte // PEM 证书链（可包含私钥）或 PFX/PKCS#12 文件
+	KeyData  []byte // 单独上传的 PEM 私钥，可为空
+	Password string // PFX 密码
+}
+
+// ImportExternalCert 上传非ACME签发的证书（商业CA、内部CA），只跟踪有效期，不关联账户和DNS提供商。
+// 证书链必须完整：逐级签名校验直到自签名根证书，或最后一级由系统信任的根证书签发
+func (s *AcmeCertServiceImpl) ImportExternalCert(ctx context.Context, req *ImportExternalCertReq) (*model.AcmeCert, error) {
+	certs, keys, err := parseExternalBundle(req.Data, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.KeyData) > 0 {
+		_, extra, err := parseExternalBundle(req.KeyData, "")
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, extra...)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("未找到证书")
+	}
+	if len(keys) > 1 {
+		return nil, errors.New("只能包含一个私钥")
+	}
+
+	var privateKey crypto.PrivateKey
+	if len(keys) == 1 {
+		privateKey = keys[0]
+	}
+	leaf, err := findLeafCert(certs, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := buildExternalChain(leaf, certs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 根证书不随证书链下发
+	var certificate, issuer bytes.Buffer
+	for i, c := range chain {
+		if i > 0 && isSelfSigned(c) {
+			break
+		}
+		block := &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}
+		_ = pem.Encode(&certificate, block)
+		if i > 0 {
+			_ = pem.Encode(&issuer, block)
+		}
+	}
+
+	info := ParseCertInfo(s.logger, certificate.String())
+	var count int64
+	if err := s.db.Model(&model.AcmeCertVersion{}).Where("serial = ?", info.Serial).Count(&count).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to count cert version")
+	}
+	if count > 0 {
+		return nil, errors.Errorf("证书已存在: %s", info.Serial)
+	}
+
+	domains := append([]string{}, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		domains = append(domains, ip.String())
+	}
+	if len(domains) == 0 && leaf.Subject.CommonName != "" {
+		domains = append(domains, leaf.Subject.CommonName)
+	}
+
+	// 已过期的证书同样按 issued 保存，是否过期由有效期判断，与统计和列表一致
+	cert := &model.AcmeCert{Model: model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		Domains: domains, Identifiers: model.NewIdentifiers(domains), Source: model.CertSourceExternal,
+		CertType: info.CertType, CertStatus: model.Issued, IssuedAt: info.IssuedAt, ValidityDays: info.ValidityDays,
+		Certificate: certificate.String(), IssuerCertificate: issuer.String(),
+	}
+	switch pub := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		cert.KeyType = certcrypto.KeyType(strconv.Itoa(pub.N.BitLen()))
+	case *ecdsa.PublicKey:
+		cert.KeyType = certcrypto.KeyType(strings.ReplaceAll(pub.Curve.Params().Name, "-", ""))
+	}
+	if privateKey != nil {
+		cert.PrivateKey = string(certcrypto.PEMEncode(privateKey))
+	}
+	if err := s.db.Create(cert).Error; err != nil {
+		return nil, errors.Wrap(err, "failure to create cert")
+	}
+	if _, err := s.CreateCertVersion(ctx, &CreateCertVersionReq{CertID: cert.ID}); err != nil {
+		s.logger.Error("failed to create cert version", zap.String("cert_id", cert.ID), zap.Error(err))
+	}
+	return cert, nil
+}
+
+// parseExternalBundle 解析 PEM 或 PFX，返回其中的证书和私钥
+func parseExternalBundle(data []byte, password string) ([]*x509.Certificate, []crypto.PrivateKey, error) {
+	var blocks []*pem.Block
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		for rest := data; ; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			blocks = append(blocks, block)
+		}
+	} else {
+		var err error
+		if blocks, err = pkcs12.ToPEM(data, password); err != nil {
+			return nil, nil, errors.Wrap(err, "无法解析PFX文件")
+		}
+	}
+
+	var certs []*x509.Certificate
+	var keys []crypto.PrivateKey
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			c, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "无法解析证书")
+			}
+			certs = append(certs, c)
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			key, err := parseExternalPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	return certs, keys, nil
+}
+
+// parseExternalPrivateKey PFX 转换出的 PRIVATE KEY 可能是 PKCS#1 或 SEC 1 编码，依次尝试
+func parseExternalPrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("无法解析私钥")
+}
+
+// findLeafCert 有私钥时按公钥匹配终端证书，否则取第一个非CA证书
+func findLeafCert(certs []*x509.Certificate, privateKey crypto.PrivateKey) (*x509.Certificate, error) {
+	if privateKey != nil {
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("不支持的私钥类型")
+		}
+		pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+		if ok {
+			for _, c := range certs {
+				if pub.Equal(c.PublicKey) {
+					return c, nil
+				}
+			}
+		}
+		return nil, errors.New("私钥与证书不匹配")
+	}
+	for _, c := range certs {
+		if !c.IsCA {
+			return c, nil
+		}
+	}
+	return nil, errors.New("未找到终端证书")
+}
+
+// buildExternalChain 从终端证书开始按签名逐级查找颁发者，链不完整时返回缺少的颁发者
+func buildExternalChain(leaf *x509.Certificate, certs []*x509.Certificate) ([]*x509.Certificate, error) {
+	chain := []*x509.Certificate{leaf}
+	for current := leaf; !isSelfSigned(current); {
+		var parent *x509.Certificate
+		for _, c := range certs {
+			if c != current && bytes.Equal(c.RawSubject, current.RawIssuer) && current.CheckSignatureFrom(c) == nil {
+				parent = c
+				break
+			}
+		}
+		if parent == nil {
+			if trustedBySystem(current) {
+				break
+			}
+			return nil, errors.Errorf("证书链不完整，缺少颁发者证书: %s", current.Issuer.CommonName)
+		}
+		if len(chain) > 10 {
+			return nil, errors.New("证书链过长")
+		}
+		chain = append(chain, parent)
+		current = parent
+	}
+	return chain, nil
+}
+
+func isSelfSigned(c *x509.Certificate) bool {
+	return bytes.Equal(c.RawSubject, c.RawIssuer) && c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature) == nil
+}
+
+// trustedBySystem 证书是否由系统信任的根证书直接签发。以证书生效时间校验，已过期的证书也能通过
+func trustedBySystem(c *x509.Certificate) bool {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		return false
+	}
+	_, err = c.Verify(x509.VerifyOptions{Roots: roots, CurrentTime: c.NotBefore,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	return err == nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"crypto"
+	"easyacme/internal/model"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/pkg/errors"
+	"net"
+	"strings"
+)
+
+// ParseIdentifiers 校验并规范化请求中的标识。identifiers 为空时由 domains 推断类型，
+// dns 标识转为小写，ip 标识转为标准格式
+func ParseIdentifiers(identifiers []model.Identifier, domains []string) (model.Identifiers, error) {
+	if len(identifiers) == 0 {
+		identifiers = model.NewIdentifiers(domains)
+	}
+	if len(identifiers) == 0 {
+		return nil, errors.New("至少需要一个域名或IP")
+	}
+
+	result := make(model.Identifiers, 0, len(identifiers))
+	seen := make(map[string]bool, len(identifiers))
+	for _, id := range identifiers {
+		value := strings.TrimSpace(id.Value)
+		switch id.Type {
+		case model.IdentifierDNS, "":
+			if net.ParseIP(value) != nil {
+				return nil, errors.Errorf("%s 是IP地址，请使用 ip 类型的标识", value)
+			}
+			if value == "" {
+				return nil, errors.New("域名不能为空")
+			}
+			id = model.Identifier{Type: model.IdentifierDNS, Value: strings.ToLower(value)}
+		case model.IdentifierIP:
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errors.Errorf("%s 不是有效的IP地址", value)
+			}
+			id = model.Identifier{Type: model.IdentifierIP, Value: ip.String()}
+		default:
+			return nil, errors.Errorf("不支持的标识类型: %s", id.Type)
+		}
+		if seen[id.Value] {
+			continue
+		}
+		seen[id.Value] = true
+		result = append(result, id)
+	}
+	return result, nil
+}
+
+// CreateCSR 为标识创建CSR，IP标识写入IP SAN。CN使用第一个域名，只有IP标识时CN为空
+func CreateCSR(privateKey crypto.PrivateKey, domains []string, mustStaple bool) ([]byte, error) {
+	var commonName string
+	for _, id := range model.NewIdentifiers(domains) {
+		if id.Type == model.IdentifierDNS {
+			commonName = id.Value
+			break
+		}
+	}
+	return certcrypto.CreateCSR(privateKey, certcrypto.CSROptions{Domain: commonName, SAN: domains, MustStaple: mustStaple})
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"easyacme/internal/model"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 导入报告中条目的处理结果
+const (
+	ImportActionCreate = "create" // 将新建（试运行）或已新建
+	ImportActionExists = "exists" // 已存在，跳过
+	ImportActionSkip   = "skip"   // 数据不完整或无法解析，跳过
+	ImportActionFailed = "failed" // 写入数据库失败
+)
+
+// ImportService 从 certbot、acme.sh、lego 的数据目录压缩包批量导入账户和证书
+type ImportService interface {
+	Import(ctx context.Context, req *ImportReq) (*ImportReport, error)
+}
+
+type ImportServiceImpl struct {
+	db              *gorm.DB
+	logger          *zap.Logger
+	acmeCertService AcmeCertService
+}
+
+// NewImportService .
+func NewImportService(db *gorm.DB, logger *zap.Logger, acmeCertService AcmeCertService) ImportService {
+	return &ImportServiceImpl{
+		db:              db,
+		logger:          logger,
+		acmeCertService: acmeCertService,
+	}
+}
+
+type ImportReq struct {
+	Data   []byte
+	DryRun bool
+}
+
+// ImportReport 导入报告，试运行时只列出将要导入的内容
+type ImportReport struct {
+	DryRun   bool                `json:"dry_run"`
+	Accounts []ImportAccountItem `json:"accounts"`
+	Certs    []ImportCertItem    `json:"certs"`
+}
+
+type ImportAccountItem struct {
+	Source  string   `json:"source"`
+	Path    string   `json:"path"`
+	Server  string   `json:"server"`
+	URI     string   `json:"uri"`
+	Email   string   `json:"email"`
+	KeyType string   `json:"key_type"`
+	Action  string   `json:"action"`
+	ID      string   `json:"id,omitempty"` // 新建或已存在的账户ID
+	Notes   []string `json:"notes,omitempty"`
+}
+
+type ImportCertItem struct {
+	Source        string                `json:"source"`
+	Path          string                `json:"path"`
+	Domains       []string              `json:"domains"`
+	KeyType       certcrypto.KeyType    `json:"key_type"`
+	Serial        string                `json:"serial"`
+	IssuedAt      *time.Time            `json:"issued_at"`
+	ExpiresAt     *time.Time            `json:"expires_at"`
+	AccountURI    string                `json:"account_uri"`
+	Solver        model.ChallengeSolver `json:"solver"`
+	Webroot       string                `json:"webroot"`
+	DNSProviderID string                `json:"dns_provider_id"`
+	AutoRenew     bool                  `json:"auto_renew"`
+	Action        string                `json:"action"`
+	ID            string                `json:"id,omitempty"`
+	Notes         []string              `json:"notes,omitempty"`
+}
+
+// Import 解析压缩包并导入其中的账户和证书。已存在的账户（按URL）和证书（按序列号）会跳过，单个条目失败不影响其余条目
+func (s *ImportServiceImpl) Import(ctx context.Context, req *ImportReq) (*ImportReport, error) {
+	files, err := readImportArchive(req.Data)
+	if err != nil {
+		return nil, err
+	}
+	accounts, certs := scanImportArchive(files)
+	if len(accounts) == 0 && len(certs) == 0 {
+		return nil, errors.New("未识别到 certbot、acme.sh 或 lego 的数据目录")
+	}
+
+	report := &ImportReport{DryRun: req.DryRun, Accounts: []ImportAccountItem{}, Certs: []ImportCertItem{}}
+	accountIDs := make(map[string]string)
+	accountURIs := make(map[string]string)
+	for _, account := range accounts {
+		item := s.importAccount(ctx, account, req.DryRun)
+		report.Accounts = append(report.Accounts, item)
+		if item.Action == ImportActionCreate || item.Action == ImportActionExists {
+			accountIDs[account.Ref] = item.ID
+			accountURIs[account.Ref] = item.URI
+		}
+	}
+	for _, cert := range certs {
+		item := s.importCert(ctx, cert, accountIDs[cert.AccountRef], accountURIs[cert.AccountRef], req.DryRun)
+		report.Certs = append(report.Certs, item)
+	}
+	return report, nil
+}
+
+func (s *ImportServiceImpl) importAccount(ctx context.Context, account *importedAccount, dryRun bool) ImportAccountItem {
+	item := ImportAccountItem{Source: string(account.Source), Path: account.Path, Server: account.Server, URI: account.URI,
+		Notes: account.Notes}
+	if len(account.Contacts) > 0 {
+		item.Email = account.Contacts[0]
+	}
+	skip := func(note string) ImportAccountItem {
+		item.Action = ImportActionSkip
+		item.Notes = append(item.Notes, note)
+		return item
+	}
+
+	if account.URI == "" {
+		return skip("账户URL未知，可使用账户私钥单独导入")
+	}
+	key, err := parseImportedAccountKey(account.KeyData)
+	if err != nil {
+		return skip(err.Error())
+	}
+	keyType, err := accountKeyType(key)
+	if err != nil {
+		return skip(err.Error())
+	}
+	item.KeyType = accountKeyTypeName(keyType)
+
+	var existing model.AcmeAccount
+	err = s.db.Where("uri = ?", account.URI).Limit(1).Find(&existing).Error
+	if err != nil {
+		return skip(err.Error())
+	}
+	if existing.ID != "" {
+		item.Action = ImportActionExists
+		item.ID = existing.ID
+		return item
+	}
+
+	item.Action = ImportActionCreate
+	if dryRun {
+		return item
+	}
+	keyPem, err := encodeAccountKey(key)
+	if err != nil {
+		return skip(err.Error())
+	}
+	status := "valid"
+	if account.Registration != nil && account.Registration.Body.Status != "" {
+		status = account.Registration.Body.Status
+	}
+	name := string(account.Source)
+	if item.Email != "" {
+		name += " " + item.Email
+	}
+	record := &model.AcmeAccount{Model: model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		Name: name, KeyPem: keyPem, KeyType: item.KeyType, Uri: account.URI, Server: account.Server, Email: item.Email,
+		Status: status, Registration: (*model.RegistrationResource)(account.Registration), Contacts: account.Contacts}
+	if err := s.db.Create(record).Error; err != nil {
+		item.Action = ImportActionFailed
+		item.Notes = append(item.Notes, err.Error())
+		return item
+	}
+	item.ID = record.ID
+	return item
+}
+
+func (s *ImportServiceImpl) importCert(ctx context.Context, cert *importedCert, accountID, accountURI string, dryRun bool) ImportCertItem {
+	item := ImportCertItem{Source: string(cert.Source), Path: cert.Path, AccountURI: accountURI, Solver: cert.Solver,
+		Webroot: cert.Webroot, Notes: cert.Notes}
+	skip := func(note string) ImportCertItem {
+		item.Action = ImportActionSkip
+		item.Notes = append(item.Notes, note)
+		return item
+	}
+
+	if cert.Certificate == "" || cert.PrivateKey == "" {
+		return skip("缺少证书或私钥文件")
+	}
+	leaf, err := certcrypto.ParsePEMCertificate([]byte(cert.Certificate))
+	if err != nil {
+		return skip("无法解析证书: " + err.Error())
+	}
+	privateKey, err := certcrypto.ParsePEMPrivateKey([]byte(cert.PrivateKey))
+	if err != nil {
+		return skip("无法解析私钥: " + err.Error())
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return skip("不支持的私钥类型")
+	}
+	if pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(leaf.PublicKey) {
+		return skip("私钥与证书不匹配")
+	}
+	keyType, err := accountKeyType(privateKey)
+	if err != nil {
+		return skip(err.Error())
+	}
+	item.KeyType = certcrypto.KeyType(keyType)
+
+	domains := append([]string{}, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		domains = append(domains, ip.String())
+	}
+	if len(domains) == 0 && leaf.Subject.CommonName != "" {
+		domains = append(domains, leaf.Subject.CommonName)
+	}
+	item.Domains = domains
+
+	info := ParseCertInfo(s.logger, cert.Certificate)
+	item.Serial, item.IssuedAt, item.ExpiresAt = info.Serial, info.IssuedAt, info.ExpiresAt
+
+	var count int64
+	if err := s.db.Model(&model.AcmeCertVersion{}).Where("serial = ?", info.Serial).Count(&count).Error; err != nil {
+		return skip(err.Error())
+	}
+	if count > 0 {
+		item.Action = ImportActionExists
+		return item
+	}
+
+	if cert.DNSType != "" {
+		var providers []model.DNSProvider
+		if err := s.db.Where("type = ?", cert.DNSType).Find(&providers).Error; err != nil {
+			return skip(err.Error())
+		}
+		if len(providers) == 1 {
+			item.DNSProviderID = providers[0].ID
+		} else {
+			item.Notes = append(item.Notes, "未找到唯一的 "+cert.DNSType.GetDisplayName()+" DNS提供商，续期前请手动选择")
+		}
+	}
+	// 试运行时新账户尚未创建，以账户URL判断是否已关联账户
+	if accountURI == "" {
+		item.Notes = append(item.Notes, "未找到对应的ACME账户，续期前请先导入账户")
+	}
+	webrootAllowed := true
+	if cert.Solver == model.SolverHTTP01Webroot {
+		if cert.Webroot == "" {
+			webrootAllowed = false
+			item.Notes = append(item.Notes, "未找到webroot目录")
+		} else if _, err := s.acmeCertService.ResolveWebroot(cert.Webroot); err != nil {
+			webrootAllowed = false
+			item.Notes = append(item.Notes, err.Error()+"，续期前请修改验证方式或在配置中允许该目录")
+		}
+	}
+	acmeCert := &model.AcmeCert{Model: model.Model{ID: uuid.New().String(), CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		Domains: domains, Identifiers: model.NewIdentifiers(domains), KeyType: item.KeyType,
+		AccountID: accountID, DNSProviderID: item.DNSProviderID, Solver: cert.Solver, Webroot: cert.Webroot,
+		KeyPolicy: model.KeyPolicyRotate, CertType: info.CertType, CertStatus: model.Issued,
+		IssuedAt: info.IssuedAt, ValidityDays: info.ValidityDays, CertURL: cert.CertURL, CertStableURL: cert.CertStableURL,
+		PrivateKey: cert.PrivateKey, Certificate: cert.Certificate, IssuerCertificate: cert.Issuer,
+	}
+	// 与签发的证书一致按 issued 保存，是否过期由有效期判断；已过期的证书不自动续期，避免导入后立即重新签发
+	expired := info.ExpiresAt != nil && info.ExpiresAt.Before(time.Now())
+	if expired {
+		item.Notes = append(item.Notes, "证书已过期，未开启自动续期")
+	}
+	acmeCert.AutoRenew = accountURI != "" && (cert.Solver != model.SolverDNS01 || acmeCert.HasDNSProvider()) &&
+		webrootAllowed && !expired
+	item.AutoRenew = acmeCert.AutoRenew
+
+	item.Action = ImportActionCreate
+	if dryRun {
+		return item
+	}
+	if err := s.db.Create(acmeCert).Error; err != nil {
+		item.Action = ImportActionFailed
+		item.Notes = append(item.Notes, err.Error())
+		return item
+	}
+	item.ID = acmeCert.ID
+	if _, err := s.acmeCertService.CreateCertVersion(ctx, &CreateCertVersionReq{CertID: acmeCert.ID}); err != nil {
+		item.Notes = append(item.Notes, err.Error())
+	}
+	return item
+}
@@ -0,0 +1,470 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"easyacme/internal/model"
+	"encoding/json"
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/pkg/errors"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxImportFiles    = 10000
+	maxImportFileSize = 1 << 20
+)
+
+// readImportArchive 读取 zip、tar 或 tar.gz 压缩包中的普通文件，返回规范化后的路径到内容的映射，跳过过大的文件
+func readImportArchive(data []byte) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	add := func(name string, r io.Reader, size int64) error {
+		if len(files) >= maxImportFiles {
+			return errors.New("压缩包中的文件过多")
+		}
+		if size > maxImportFileSize {
+			return nil
+		}
+		content, err := io.ReadAll(io.LimitReader(r, maxImportFileSize))
+		if err != nil {
+			return err
+		}
+		files[cleanImportPath(name)] = content
+		return nil
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, errors.Wrap(err, "无效的zip文件")
+		}
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, errors.Wrapf(err, "读取 %s 失败", f.Name)
+			}
+			err = add(f.Name, rc, int64(f.UncompressedSize64))
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		var r io.Reader = bytes.NewReader(data)
+		if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, errors.Wrap(err, "无效的gzip文件")
+			}
+			defer gz.Close()
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, errors.Wrap(err, "无效的tar文件")
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := add(hdr.Name, tr, hdr.Size); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("压缩包中没有可读取的文件")
+	}
+	return files, nil
+}
+
+func cleanImportPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
+
+// importSource 导入数据来源
+type importSource string
+
+const (
+	importSourceCertbot importSource = "certbot"
+	importSourceAcmeSh  importSource = "acme.sh"
+	importSourceLego    importSource = "lego"
+)
+
+// importedAccount 从压缩包中识别出的ACME账户
+type importedAccount struct {
+	Source       importSource
+	Ref          string // 账户在压缩包中的标识，证书据此关联账户
+	Path         string
+	Server       string
+	URI          string
+	KeyData      string
+	Contacts     []string
+	Registration *registration.Resource
+	Notes        []string
+}
+
+// importedCert 从压缩包中识别出的证书及其续期参数
+type importedCert struct {
+	Source        importSource
+	Name          string
+	Path          string
+	AccountRef    string
+	Certificate   string
+	PrivateKey    string
+	Issuer        string
+	CertURL       string
+	CertStableURL string
+	Solver        model.ChallengeSolver
+	Webroot       string
+	DNSType       model.DNSType
+	Notes         []string
+}
+
+var (
+	certbotAccountRe = regexp.MustCompile(`^(.*?)accounts/(.+)/([^/]+)/private_key\.json$`)
+	certbotRenewalRe = regexp.MustCompile(`^(.*?)renewal/([^/]+)\.conf$`)
+	certbotArchiveRe = regexp.MustCompile(`^cert(\d+)\.pem$`)
+	acmeShAccountRe  = regexp.MustCompile(`^(.*?)ca/(.+)/account\.key$`)
+	acmeShCertRe     = regexp.MustCompile(`^(.*?)([^/]+)/([^/]+)\.conf$`)
+	legoAccountRe    = regexp.MustCompile(`^(.*?)accounts/([^/]+)/([^/]+)/account\.json$`)
+	legoCertRe       = regexp.MustCompile(`^(.*?)certificates/([^/]+)\.crt$`)
+)
+
+// certbotDNSTypes certbot DNS插件对应的DNS提供商类型
+var certbotDNSTypes = map[string]model.DNSType{
+	"dns-cloudflare":   model.DNSTypeCloudflare,
+	"dns-route53":      model.DNSTypeRoute53,
+	"dns-aliyun":       model.DNSTypeAliyun,
+	"dns-tencentcloud": model.DNSTypeTencentCloud,
+	"dns-dnspod":       model.DNSTypeTencentCloud,
+	"dns-huaweicloud":  model.DNSTypeHuaweiCloud,
+	"dns-godaddy":      model.DNSTypeGoDaddy,
+}
+
+// acmeShDNSTypes acme.sh DNS API 对应的DNS提供商类型
+var acmeShDNSTypes = map[string]model.DNSType{
+	"dns_cf":          model.DNSTypeCloudflare,
+	"dns_aws":         model.DNSTypeRoute53,
+	"dns_ali":         model.DNSTypeAliyun,
+	"dns_tencent":     model.DNSTypeTencentCloud,
+	"dns_dp":          model.DNSTypeTencentCloud,
+	"dns_huaweicloud": model.DNSTypeHuaweiCloud,
+	"dns_gd":          model.DNSTypeGoDaddy,
+	"dns_acmedns":     model.DNSTypeAcmeDNS,
+}
+
+// scanImportArchive 按 certbot、acme.sh 和 lego 的目录结构识别账户和证书
+func scanImportArchive(files map[string][]byte) ([]*importedAccount, []*importedCert) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var accounts []*importedAccount
+	var certs []*importedCert
+	for _, name := range names {
+		switch {
+		case certbotAccountRe.MatchString(name):
+			accounts = append(accounts, scanCertbotAccount(files, name))
+		case certbotRenewalRe.MatchString(name):
+			certs = append(certs, scanCertbotCert(files, name))
+		case acmeShAccountRe.MatchString(name):
+			accounts = append(accounts, scanAcmeShAccount(files, name))
+		case legoAccountRe.MatchString(name):
+			accounts = append(accounts, scanLegoAccount(files, name))
+		case legoCertRe.MatchString(name) && !strings.HasSuffix(name, ".issuer.crt"):
+			certs = append(certs, scanLegoCert(files, name))
+		case acmeShCertRe.MatchString(name):
+			if cert := scanAcmeShCert(files, name); cert != nil {
+				certs = append(certs, cert)
+			}
+		}
+	}
+
+	// lego 不记录证书使用的账户，压缩包中只有一个 lego 账户时直接关联
+	var legoAccounts []*importedAccount
+	for _, account := range accounts {
+		if account.Source == importSourceLego {
+			legoAccounts = append(legoAccounts, account)
+		}
+	}
+	for _, cert := range certs {
+		if cert.Source != importSourceLego {
+			continue
+		}
+		if len(legoAccounts) == 1 {
+			cert.AccountRef = legoAccounts[0].Ref
+		} else {
+			cert.Notes = append(cert.Notes, "lego 未记录证书所属账户，请导入后手动选择账户")
+		}
+	}
+	return accounts, certs
+}
+
+// scanCertbotAccount accounts/<server>/<hash>/ 下的 private_key.json(JWK) 和 regr.json
+func scanCertbotAccount(files map[string][]byte, name string) *importedAccount {
+	m := certbotAccountRe.FindStringSubmatch(name)
+	dir := path.Dir(name)
+	account := &importedAccount{Source: importSourceCertbot, Ref: "certbot:" + m[3], Path: dir,
+		Server: "https://" + m[2], KeyData: string(files[name])}
+
+	var regr struct {
+		Body acme.Account `json:"body"`
+		URI  string       `json:"uri"`
+	}
+	if data, ok := files[dir+"/regr.json"]; ok && json.Unmarshal(data, &regr) == nil {
+		account.URI = regr.URI
+		account.Contacts = mailtoContacts(regr.Body.Contact)
+		account.Registration = &registration.Resource{URI: regr.URI, Body: regr.Body}
+	} else {
+		account.Notes = append(account.Notes, "缺少 regr.json")
+	}
+	return account
+}
+
+// scanCertbotCert renewal/<name>.conf 及 archive/<name>/ 下编号最大的证书文件
+func scanCertbotCert(files map[string][]byte, name string) *importedCert {
+	m := certbotRenewalRe.FindStringSubmatch(name)
+	prefix, lineage := m[1], m[2]
+	conf := parseCertbotRenewalConf(files[name])
+	cert := &importedCert{Source: importSourceCertbot, Name: lineage, Path: name,
+		AccountRef: "certbot:" + conf["account"]}
+
+	archiveDir := prefix + "archive/" + lineage + "/"
+	latest := 0
+	for file := range files {
+		if rest, ok := strings.CutPrefix(file, archiveDir); ok {
+			if mm := certbotArchiveRe.FindStringSubmatch(rest); mm != nil {
+				if n, _ := strconv.Atoi(mm[1]); n > latest {
+					latest = n
+				}
+			}
+		}
+	}
+	read := func(base string) string {
+		if latest > 0 {
+			if data, ok := files[archiveDir+base+strconv.Itoa(latest)+".pem"]; ok {
+				return string(data)
+			}
+		}
+		return string(files[prefix+"live/"+lineage+"/"+base+".pem"])
+	}
+	cert.Certificate = read("fullchain")
+	if cert.Certificate == "" {
+		cert.Certificate = read("cert")
+	}
+	cert.PrivateKey = read("privkey")
+	cert.Issuer = read("chain")
+
+	authenticator := conf["authenticator"]
+	switch {
+	case authenticator == "webroot":
+		cert.Solver = model.SolverHTTP01Webroot
+		cert.Webroot = strings.Split(strings.TrimSuffix(conf["webroot_path"], ","), ",")[0]
+		if cert.Webroot == "" {
+			for key, value := range conf {
+				if strings.HasPrefix(key, "webroot_map.") {
+					cert.Webroot = value
+					break
+				}
+			}
+		}
+	case authenticator == "standalone":
+		cert.Solver = model.SolverHTTP01Standalone
+	case strings.HasPrefix(authenticator, "dns-"):
+		cert.Solver = model.SolverDNS01
+		if dnsType, ok := certbotDNSTypes[authenticator]; ok {
+			cert.DNSType = dnsType
+		} else {
+			cert.Notes = append(cert.Notes, "无法识别的DNS插件 "+authenticator+"，续期需手动验证或选择DNS提供商")
+		}
+	default:
+		cert.Solver = model.SolverDNS01
+		cert.Notes = append(cert.Notes, "无法映射的验证方式 "+authenticator+"，按手动DNS-01导入")
+	}
+	return cert
+}
+
+// parseCertbotRenewalConf 解析 certbot 续期配置，[[webroot_map]] 段中的键加 webroot_map. 前缀
+func parseCertbotRenewalConf(data []byte) map[string]string {
+	conf := make(map[string]string)
+	var section string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[]")
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if section == "webroot_map" {
+			key = "webroot_map." + key
+		}
+		conf[key] = value
+	}
+	return conf
+}
+
+// scanAcmeShAccount ca/<server>/account.key，账户URL记录在同目录的 ca.conf
+func scanAcmeShAccount(files map[string][]byte, name string) *importedAccount {
+	m := acmeShAccountRe.FindStringSubmatch(name)
+	dir := path.Dir(name)
+	server := "https://" + m[2]
+	account := &importedAccount{Source: importSourceAcmeSh, Ref: "acme.sh:" + server, Path: dir,
+		Server: server, KeyData: string(files[name])}
+
+	account.URI = parseShellConf(files[dir+"/ca.conf"])["ACCOUNT_URL"]
+	var body acme.Account
+	if data, ok := files[dir+"/account.json"]; ok && json.Unmarshal(data, &body) == nil {
+		account.Contacts = mailtoContacts(body.Contact)
+		account.Registration = &registration.Resource{URI: account.URI, Body: body}
+	}
+	if account.URI == "" {
+		account.Notes = append(account.Notes, "ca.conf 中缺少 ACCOUNT_URL")
+	}
+	return account
+}
+
+// scanAcmeShCert <domain>[_ecc]/<domain>.conf 及同目录的 .cer/.key
+func scanAcmeShCert(files map[string][]byte, name string) *importedCert {
+	m := acmeShCertRe.FindStringSubmatch(name)
+	dir, base := m[2], m[3]
+	if dir != base && dir != base+"_ecc" {
+		return nil
+	}
+	conf := parseShellConf(files[name])
+	if conf["Le_Domain"] == "" {
+		return nil
+	}
+	prefix := m[1] + dir + "/"
+	cert := &importedCert{Source: importSourceAcmeSh, Name: conf["Le_Domain"], Path: name,
+		AccountRef: "acme.sh:" + conf["Le_API"]}
+	cert.Certificate = string(files[prefix+"fullchain.cer"])
+	if cert.Certificate == "" {
+		cert.Certificate = string(files[prefix+base+".cer"])
+	}
+	cert.PrivateKey = string(files[prefix+base+".key"])
+	cert.Issuer = string(files[prefix+"ca.cer"])
+
+	webroot := strings.Split(conf["Le_Webroot"], ",")[0]
+	switch {
+	case webroot == "no":
+		cert.Solver = model.SolverHTTP01Standalone
+	case webroot == "alpn":
+		cert.Solver = model.SolverTLSALPN01
+	case webroot == "dns":
+		cert.Solver = model.SolverDNS01
+	case strings.HasPrefix(webroot, "dns_"):
+		cert.Solver = model.SolverDNS01
+		if dnsType, ok := acmeShDNSTypes[webroot]; ok {
+			cert.DNSType = dnsType
+		} else {
+			cert.Notes = append(cert.Notes, "无法识别的DNS API "+webroot+"，续期需手动验证或选择DNS提供商")
+		}
+	case strings.HasPrefix(webroot, "/"):
+		cert.Solver = model.SolverHTTP01Webroot
+		cert.Webroot = webroot
+	default:
+		cert.Solver = model.SolverDNS01
+		cert.Notes = append(cert.Notes, "无法映射的验证方式 "+webroot+"，按手动DNS-01导入")
+	}
+	return cert
+}
+
+// parseShellConf 解析 acme.sh 的 KEY='value' 格式配置
+func parseShellConf(data []byte) map[string]string {
+	conf := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		conf[key] = strings.Trim(value, `'"`)
+	}
+	return conf
+}
+
+// scanLegoAccount accounts/<host>/<email>/account.json 及 keys/<email>.key
+func scanLegoAccount(files map[string][]byte, name string) *importedAccount {
+	m := legoAccountRe.FindStringSubmatch(name)
+	dir := path.Dir(name)
+	// lego 只保存目录URL的主机名（端口中的 : 替换为 _），按 /directory 还原
+	server := "https://" + strings.ReplaceAll(m[2], "_", ":") + "/directory"
+	account := &importedAccount{Source: importSourceLego, Ref: "lego:" + m[2] + "/" + m[3], Path: dir,
+		Server: server, KeyData: string(files[dir+"/keys/"+m[3]+".key"])}
+	account.Notes = append(account.Notes, "目录URL由主机名推断: "+server)
+
+	var data struct {
+		Email        string `json:"email"`
+		Registration *struct {
+			Body acme.Account `json:"body"`
+			URI  string       `json:"uri"`
+		} `json:"registration"`
+	}
+	if err := json.Unmarshal(files[name], &data); err == nil && data.Registration != nil {
+		account.URI = data.Registration.URI
+		account.Contacts = mailtoContacts(data.Registration.Body.Contact)
+		account.Registration = &registration.Resource{URI: data.Registration.URI, Body: data.Registration.Body}
+	}
+	if len(account.Contacts) == 0 && data.Email != "" {
+		account.Contacts = []string{data.Email}
+	}
+	return account
+}
+
+// scanLegoCert certificates/<domain>.crt 及同名 .key、.issuer.crt、.json
+func scanLegoCert(files map[string][]byte, name string) *importedCert {
+	m := legoCertRe.FindStringSubmatch(name)
+	prefix := m[1] + "certificates/" + m[2]
+	cert := &importedCert{Source: importSourceLego, Name: m[2], Path: name, Solver: model.SolverDNS01,
+		Certificate: string(files[name]), PrivateKey: string(files[prefix+".key"]), Issuer: string(files[prefix+".issuer.crt"])}
+	cert.Notes = append(cert.Notes, "lego 未记录验证方式，按手动DNS-01导入")
+
+	var resource struct {
+		CertURL       string `json:"certUrl"`
+		CertStableURL string `json:"certStableUrl"`
+	}
+	if json.Unmarshal(files[prefix+".json"], &resource) == nil {
+		cert.CertURL = resource.CertURL
+		cert.CertStableURL = resource.CertStableURL
+	}
+	return cert
+}
+
+// mailtoContacts 从 contact URL 中提取邮箱
+func mailtoContacts(contacts []string) []string {
+	var emails []string
+	for _, contact := range contacts {
+		if email, ok := strings.CutPrefix(contact, "mailto:"); ok {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
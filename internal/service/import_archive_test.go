@@ -0,0 +1,238 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"easyacme/internal/model"
+	"reflect"
+	"testing"
+)
+
+func TestCleanImportPath(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"etc/letsencrypt/renewal/a.conf", "etc/letsencrypt/renewal/a.conf"},
+		{"/etc/letsencrypt/../letsencrypt/a.conf", "etc/letsencrypt/a.conf"},
+		{"../../etc/passwd", "etc/passwd"},
+		{`acme.sh\example.com\example.com.conf`, "acme.sh/example.com/example.com.conf"},
+	}
+	for _, tt := range tests {
+		if got := cleanImportPath(tt.name); got != tt.want {
+			t.Errorf("cleanImportPath(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseCertbotRenewalConf(t *testing.T) {
+	data := []byte(`# renew_before_expiry = 30 days
+version = 2.0.0
+archive_dir = /etc/letsencrypt/archive/example.com
+
+[renewalparams]
+account = 0123abcd
+authenticator = webroot
+webroot_path = /var/www/html,
+[[webroot_map]]
+example.com = /var/www/html
+www.example.com = /var/www/www
+`)
+	want := map[string]string{
+		"version":                     "2.0.0",
+		"archive_dir":                 "/etc/letsencrypt/archive/example.com",
+		"account":                     "0123abcd",
+		"authenticator":               "webroot",
+		"webroot_path":                "/var/www/html,",
+		"webroot_map.example.com":     "/var/www/html",
+		"webroot_map.www.example.com": "/var/www/www",
+	}
+	if got := parseCertbotRenewalConf(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCertbotRenewalConf() = %v, want %v", got, want)
+	}
+}
+
+func TestParseShellConf(t *testing.T) {
+	data := []byte(`Le_Domain='example.com'
+Le_Alt='www.example.com'
+#Le_Webroot='no'
+Le_Webroot="dns_cf"
+Le_API='https://acme-v02.api.letsencrypt.org/directory'
+Le_Keylength=ec-256
+broken line
+`)
+	want := map[string]string{
+		"Le_Domain":    "example.com",
+		"Le_Alt":       "www.example.com",
+		"Le_Webroot":   "dns_cf",
+		"Le_API":       "https://acme-v02.api.letsencrypt.org/directory",
+		"Le_Keylength": "ec-256",
+	}
+	if got := parseShellConf(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseShellConf() = %v, want %v", got, want)
+	}
+}
+
+func TestScanAcmeShCertSolver(t *testing.T) {
+	tests := []struct {
+		webroot string
+		solver  model.ChallengeSolver
+		webdir  string
+		dnsType model.DNSType
+		notes   int
+	}{
+		{"no", model.SolverHTTP01Standalone, "", "", 0},
+		{"alpn", model.SolverTLSALPN01, "", "", 0},
+		{"dns", model.SolverDNS01, "", "", 0},
+		{"dns_cf", model.SolverDNS01, "", model.DNSTypeCloudflare, 0},
+		{"dns_unknown", model.SolverDNS01, "", "", 1},
+		{"/var/www/html,/var/www/other", model.SolverHTTP01Webroot, "/var/www/html", "", 0},
+		{"stateless", model.SolverDNS01, "", "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.webroot, func(t *testing.T) {
+			files := map[string][]byte{
+				"acme.sh/example.com/example.com.conf": []byte("Le_Domain='example.com'\nLe_Webroot='" + tt.webroot + "'\n"),
+			}
+			cert := scanAcmeShCert(files, "acme.sh/example.com/example.com.conf")
+			if cert == nil {
+				t.Fatal("scanAcmeShCert() = nil")
+			}
+			if cert.Solver != tt.solver || cert.Webroot != tt.webdir || cert.DNSType != tt.dnsType || len(cert.Notes) != tt.notes {
+				t.Errorf("scanAcmeShCert() solver=%q webroot=%q dns=%q notes=%v", cert.Solver, cert.Webroot, cert.DNSType, cert.Notes)
+			}
+		})
+	}
+}
+
+func TestScanCertbotCertSolver(t *testing.T) {
+	tests := []struct {
+		conf    string
+		solver  model.ChallengeSolver
+		webroot string
+		dnsType model.DNSType
+		notes   int
+	}{
+		{"authenticator = standalone", model.SolverHTTP01Standalone, "", "", 0},
+		{"authenticator = webroot\nwebroot_path = /var/www/html,", model.SolverHTTP01Webroot, "/var/www/html", "", 0},
+		{"authenticator = webroot\n[[webroot_map]]\nexample.com = /srv/www", model.SolverHTTP01Webroot, "/srv/www", "", 0},
+		{"authenticator = dns-cloudflare", model.SolverDNS01, "", model.DNSTypeCloudflare, 0},
+		{"authenticator = dns-unknown", model.SolverDNS01, "", "", 1},
+		{"authenticator = manual", model.SolverDNS01, "", "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conf, func(t *testing.T) {
+			files := map[string][]byte{"renewal/example.com.conf": []byte("[renewalparams]\n" + tt.conf + "\n")}
+			cert := scanCertbotCert(files, "renewal/example.com.conf")
+			if cert.Solver != tt.solver || cert.Webroot != tt.webroot || cert.DNSType != tt.dnsType || len(cert.Notes) != tt.notes {
+				t.Errorf("scanCertbotCert() solver=%q webroot=%q dns=%q notes=%v", cert.Solver, cert.Webroot, cert.DNSType, cert.Notes)
+			}
+		})
+	}
+}
+
+func TestScanCertbotCertLatestArchive(t *testing.T) {
+	files := map[string][]byte{
+		"letsencrypt/renewal/example.com.conf":            []byte("[renewalparams]\naccount = abc\nauthenticator = standalone\n"),
+		"letsencrypt/archive/example.com/fullchain1.pem":  []byte("fullchain1"),
+		"letsencrypt/archive/example.com/fullchain2.pem":  []byte("fullchain2"),
+		"letsencrypt/archive/example.com/fullchain10.pem": []byte("fullchain10"),
+		"letsencrypt/archive/example.com/cert10.pem":      []byte("cert10"),
+		"letsencrypt/archive/example.com/privkey10.pem":   []byte("privkey10"),
+		"letsencrypt/archive/example.com/chain10.pem":     []byte("chain10"),
+		"letsencrypt/live/example.com/fullchain.pem":      []byte("live"),
+	}
+	cert := scanCertbotCert(files, "letsencrypt/renewal/example.com.conf")
+	if cert.Certificate != "fullchain10" || cert.PrivateKey != "privkey10" || cert.Issuer != "chain10" {
+		t.Errorf("scanCertbotCert() read %q, %q, %q", cert.Certificate, cert.PrivateKey, cert.Issuer)
+	}
+	if cert.AccountRef != "certbot:abc" {
+		t.Errorf("AccountRef = %q", cert.AccountRef)
+	}
+}
+
+func TestScanImportArchive(t *testing.T) {
+	files := map[string][]byte{
+		// certbot
+		"letsencrypt/accounts/acme-v02.api.letsencrypt.org/directory/abc/private_key.json": []byte("{}"),
+		"letsencrypt/accounts/acme-v02.api.letsencrypt.org/directory/abc/regr.json": []byte(
+			`{"body":{"contact":["mailto:a@example.com"]},"uri":"https://acme-v02.api.letsencrypt.org/acme/acct/1"}`),
+		"letsencrypt/renewal/certbot.example.com.conf": []byte("[renewalparams]\naccount = abc\nauthenticator = standalone\n"),
+		// acme.sh
+		"acme.sh/ca/acme-v02.api.letsencrypt.org/directory/account.key": []byte("key"),
+		"acme.sh/ca/acme-v02.api.letsencrypt.org/directory/ca.conf":     []byte("ACCOUNT_URL='https://acme-v02.api.letsencrypt.org/acme/acct/2'\n"),
+		"acme.sh/sh.example.com_ecc/sh.example.com.conf": []byte(
+			"Le_Domain='sh.example.com'\nLe_Webroot='alpn'\nLe_API='https://acme-v02.api.letsencrypt.org/directory'\n"),
+		"acme.sh/sh.example.com_ecc/sh.example.com.cer": []byte("cer"),
+		"acme.sh/other/unrelated.conf":                  []byte("Le_Domain='x'\n"),
+		// lego
+		".lego/accounts/acme-staging-v02.api.letsencrypt.org/b@example.com/account.json": []byte(
+			`{"email":"b@example.com","registration":{"body":{"status":"valid"},"uri":"https://staging/acct/3"}}`),
+		".lego/accounts/acme-staging-v02.api.letsencrypt.org/b@example.com/keys/b@example.com.key": []byte("key"),
+		".lego/certificates/lego.example.com.crt":                                                  []byte("crt"),
+		".lego/certificates/lego.example.com.issuer.crt":                                           []byte("issuer"),
+		".lego/certificates/lego.example.com.json":                                                 []byte(`{"certUrl":"https://staging/cert/1"}`),
+	}
+	accounts, certs := scanImportArchive(files)
+
+	accountRefs := map[string]*importedAccount{}
+	for _, a := range accounts {
+		accountRefs[a.Ref] = a
+	}
+	if len(accounts) != 3 {
+		t.Fatalf("scanImportArchive() found %d accounts, want 3", len(accounts))
+	}
+	if a := accountRefs["certbot:abc"]; a == nil || a.Server != "https://acme-v02.api.letsencrypt.org/directory" ||
+		a.URI != "https://acme-v02.api.letsencrypt.org/acme/acct/1" || !reflect.DeepEqual(a.Contacts, []string{"a@example.com"}) {
+		t.Errorf("certbot account = %+v", a)
+	}
+	if a := accountRefs["acme.sh:https://acme-v02.api.letsencrypt.org/directory"]; a == nil ||
+		a.URI != "https://acme-v02.api.letsencrypt.org/acme/acct/2" || a.KeyData != "key" {
+		t.Errorf("acme.sh account = %+v", a)
+	}
+	if a := accountRefs["lego:acme-staging-v02.api.letsencrypt.org/b@example.com"]; a == nil ||
+		a.Server != "https://acme-staging-v02.api.letsencrypt.org/directory" || a.URI != "https://staging/acct/3" || a.KeyData != "key" {
+		t.Errorf("lego account = %+v", a)
+	}
+
+	certNames := map[string]*importedCert{}
+	for _, c := range certs {
+		certNames[c.Name] = c
+	}
+	if len(certs) != 3 {
+		t.Fatalf("scanImportArchive() found %d certs, want 3", len(certs))
+	}
+	if c := certNames["certbot.example.com"]; c == nil || c.AccountRef != "certbot:abc" || c.Solver != model.SolverHTTP01Standalone {
+		t.Errorf("certbot cert = %+v", c)
+	}
+	if c := certNames["sh.example.com"]; c == nil || c.Certificate != "cer" || c.Solver != model.SolverTLSALPN01 ||
+		c.AccountRef != "acme.sh:https://acme-v02.api.letsencrypt.org/directory" {
+		t.Errorf("acme.sh cert = %+v", c)
+	}
+	// 只有一个 lego 账户时证书直接关联该账户
+	if c := certNames["lego.example.com"]; c == nil || c.AccountRef != "lego:acme-staging-v02.api.letsencrypt.org/b@example.com" ||
+		c.Issuer != "issuer" || c.CertURL != "https://staging/cert/1" {
+		t.Errorf("lego cert = %+v", c)
+	}
+}
+
+func TestReadImportArchiveZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"/renewal/a.conf": "a", `live\a\cert.pem`: "b"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := readImportArchive(buf.Bytes())
+	if err != nil {
+		t.Fatalf("readImportArchive() error = %v", err)
+	}
+	want := map[string][]byte{"renewal/a.conf": []byte("a"), "live/a/cert.pem": []byte("b")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("readImportArchive() = %v, want %v", files, want)
+	}
+}
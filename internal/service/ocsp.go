@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"easyacme/internal/config"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const defaultOCSPInterval = 12 * time.Hour
+
+// OCSPService 证书OCSP状态检查服务，后台定期查询已签发证书的吊销状态并缓存OCSP响应
+type OCSPService interface {
+	Start()
+	Stop()
+	RunOnce(ctx context.Context)
+}
+
+type OCSPServiceImpl struct {
+	logger          *zap.Logger
+	acmeCertService AcmeCertService
+	enabled         bool
+	interval        time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewOCSPService .
+func NewOCSPService(cfg *config.Config, logger *zap.Logger, acmeCertService AcmeCertService) OCSPService {
+	interval := time.Duration(cfg.OCSP.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = defaultOCSPInterval
+	}
+	return &OCSPServiceImpl{
+		logger:          logger,
+		acmeCertService: acmeCertService,
+		enabled:         cfg.OCSP.Enabled,
+		interval:        interval,
+	}
+}
+
+// Start 启动后台OCSP检查循环，启动后立即执行一次检查
+func (o *OCSPServiceImpl) Start() {
+	if !o.enabled {
+		o.logger.Info("OCSP check is disabled")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		ticker := time.NewTicker(o.interval)
+		defer ticker.Stop()
+
+		o.RunOnce(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				o.RunOnce(ctx)
+			}
+		}
+	}()
+	o.logger.Info("OCSP check started", zap.Duration("interval", o.interval))
+}
+
+// Stop 停止OCSP检查循环并等待正在进行的检查结束
+func (o *OCSPServiceImpl) Stop() {
+	if o.cancel == nil {
+		return
+	}
+	o.cancel()
+	o.wg.Wait()
+	o.logger.Info("OCSP check stopped")
+}
+
+// RunOnce 逐个检查证书的OCSP状态，单个证书失败不影响其他证书
+func (o *OCSPServiceImpl) RunOnce(ctx context.Context) {
+	certs, err := o.acmeCertService.GetCertsForOCSPCheck(ctx)
+	if err != nil {
+		o.logger.Error("failed to get certs for ocsp check", zap.Error(err))
+		return
+	}
+
+	for _, cert := range certs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := o.acmeCertService.CheckOCSP(ctx, &CheckOCSPReq{ID: cert.ID}); err != nil {
+			o.logger.Warn("failed to check ocsp", zap.String("cert_id", cert.ID), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"easyacme/internal/config"
+	"easyacme/internal/model"
+	"fmt"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPropagationTimeout = 5 * time.Minute
+	propagationQueryTimeout   = 5 * time.Second
+	defaultResolvConf         = "/etc/resolv.conf"
+)
+
+// fallbackResolvers 系统DNS配置不可用时使用的递归DNS
+var fallbackResolvers = []string{"8.8.8.8:53", "1.1.1.1:53"}
+
+// PropagationService 检查DNS-01 TXT记录是否已在区域的所有权威DNS服务器上生效
+type PropagationService interface {
+	Check(ctx context.Context, req *CheckPropagationReq) *model.DNSRecordStatus
+	Wait(ctx context.Context, req *CheckPropagationReq, onStatus func(status *model.DNSRecordStatus)) error
+	Resolvers() []string
+}
+
+type PropagationServiceImpl struct {
+	logger    *zap.Logger
+	resolvers []string
+	timeout   time.Duration
+}
+
+// NewPropagationService .
+func NewPropagationService(cfg *config.Config, logger *zap.Logger) PropagationService {
+	resolvers := dns01.ParseNameservers(cfg.Challenge.DNSResolvers)
+	if len(resolvers) == 0 {
+		resolvers = fallbackResolvers
+		if conf, err := dns.ClientConfigFromFile(defaultResolvConf); err == nil && len(conf.Servers) > 0 {
+			resolvers = dns01.ParseNameservers(conf.Servers)
+		}
+	}
+	timeout := time.Duration(cfg.Challenge.PropagationTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultPropagationTimeout
+	}
+	return &PropagationServiceImpl{
+		logger:    logger,
+		resolvers: resolvers,
+		timeout:   timeout,
+	}
+}
+
+type CheckPropagationReq struct {
+	Domain string
+	FQDN   string // 实际设置TXT记录的域名，已经过CNAME解析
+	Value  string
+}
+
+// Resolvers 返回使用的递归DNS
+func (p *PropagationServiceImpl) Resolvers() []string {
+	return p.resolvers
+}
+
+// Check 直接查询区域的每个权威DNS服务器，返回各服务器上的TXT记录状态
+func (p *PropagationServiceImpl) Check(ctx context.Context, req *CheckPropagationReq) *model.DNSRecordStatus {
+	status := &model.DNSRecordStatus{Domain: req.Domain, FQDN: dns.Fqdn(req.FQDN), Value: req.Value, CheckedAt: time.Now()}
+
+	nameservers, zone, err := p.findAuthoritativeServers(ctx, status.FQDN)
+	status.Zone = zone
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	status.Propagated = true
+	for _, ns := range nameservers {
+		nsStatus := model.NameserverStatus{Nameserver: ns}
+		values, err := p.queryTXT(ctx, status.FQDN, ns)
+		if err != nil {
+			nsStatus.Error = err.Error()
+		}
+		nsStatus.Values = values
+		nsStatus.Found = slices.Contains(values, req.Value)
+		status.Propagated = status.Propagated && nsStatus.Found
+		status.Nameservers = append(status.Nameservers, nsStatus)
+	}
+	return status
+}
+
+// Wait 按指数退避反复检查，直到所有权威DNS服务器都返回期望的值或超时，每次检查后通过 onStatus 上报状态
+func (p *PropagationServiceImpl) Wait(ctx context.Context, req *CheckPropagationReq, onStatus func(status *model.DNSRecordStatus)) error {
+	bo := backoff.NewExponentialBackOff()
+	bo.InitialInterval = 2 * time.Second
+	bo.MaxInterval = 30 * time.Second
+	bo.MaxElapsedTime = p.timeout
+
+	var last *model.DNSRecordStatus
+	operation := func() error {
+		last = p.Check(ctx, req)
+		if onStatus != nil {
+			onStatus(last)
+		}
+		if last.Propagated {
+			return nil
+		}
+		p.logger.Debug("txt record not propagated yet", zap.String("fqdn", last.FQDN), zap.String("error", last.Error))
+		return errors.New(describePropagation(last))
+	}
+
+	if err := backoff.Retry(operation, backoff.WithContext(bo, ctx)); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("等待 %s 的TXT记录生效超时: %w", req.FQDN, err)
+	}
+	return nil
+}
+
+// describePropagation 汇总尚未生效的权威DNS服务器
+func describePropagation(status *model.DNSRecordStatus) string {
+	if status.Error != "" {
+		return status.Error
+	}
+	var pending []string
+	for _, ns := range status.Nameservers {
+		if !ns.Found {
+			pending = append(pending, ns.Nameserver)
+		}
+	}
+	return fmt.Sprintf("%d/%d 个权威DNS服务器尚未返回期望的TXT记录: %s",
+		len(pending), len(status.Nameservers), strings.Join(pending, ", "))
+}
+
+// findAuthoritativeServers 查找fqdn所在区域及其权威DNS服务器地址
+func (p *PropagationServiceImpl) findAuthoritativeServers(ctx context.Context, fqdn string) ([]string, string, error) {
+	zone, err := dns01.FindZoneByFqdnCustom(fqdn, p.resolvers)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failure to find zone")
+	}
+
+	msg, err := p.queryResolvers(ctx, zone, dns.TypeNS)
+	if err != nil {
+		return nil, zone, errors.Wrapf(err, "failure to query NS of %s", zone)
+	}
+	var nameservers []string
+	for _, rr := range msg.Answer {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+		addr, err := p.resolveNameserver(ctx, ns.Ns)
+		if err != nil {
+			p.logger.Warn("failure to resolve nameserver", zap.String("ns", ns.Ns), zap.Error(err))
+			continue
+		}
+		nameservers = append(nameservers, addr)
+	}
+	if len(nameservers) == 0 {
+		return nil, zone, fmt.Errorf("区域 %s 没有可用的权威DNS服务器", zone)
+	}
+	return nameservers, zone, nil
+}
+
+// resolveNameserver 通过递归DNS解析权威DNS服务器的IP地址
+func (p *PropagationServiceImpl) resolveNameserver(ctx context.Context, host string) (string, error) {
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg, err := p.queryResolvers(ctx, host, qtype)
+		if err != nil {
+			continue
+		}
+		for _, rr := range msg.Answer {
+			switch v := rr.(type) {
+			case *dns.A:
+				return net.JoinHostPort(v.A.String(), "53"), nil
+			case *dns.AAAA:
+				return net.JoinHostPort(v.AAAA.String(), "53"), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no address for %s", host)
+}
+
+// queryResolvers 依次向递归DNS查询，返回第一个成功的应答
+func (p *PropagationServiceImpl) queryResolvers(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.SetEdns0(4096, false)
+
+	var lastErr error
+	for _, resolver := range p.resolvers {
+		in, err := exchange(ctx, m, resolver)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if in.Rcode != dns.RcodeSuccess {
+			lastErr = fmt.Errorf("%s returned %s", resolver, dns.RcodeToString[in.Rcode])
+			continue
+		}
+		return in, nil
+	}
+	return nil, lastErr
+}
+
+// queryTXT 直接向权威DNS服务器查询TXT记录，不使用递归
+func (p *PropagationServiceImpl) queryTXT(ctx context.Context, fqdn, nameserver string) ([]string, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(fqdn, dns.TypeTXT)
+	m.SetEdns0(4096, false)
+	m.RecursionDesired = false
+
+	in, err := exchange(ctx, m, nameserver)
+	if err != nil {
+		return nil, err
+	}
+	if in.Rcode != dns.RcodeSuccess && in.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s returned %s", nameserver, dns.RcodeToString[in.Rcode])
+	}
+	var values []string
+	for _, rr := range in.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			values = append(values, strings.Join(txt.Txt, ""))
+		}
+	}
+	return values, nil
+}
+
+// exchange 发送UDP查询，应答被截断时改用TCP
+func exchange(ctx context.Context, m *dns.Msg, server string) (*dns.Msg, error) {
+	client := &dns.Client{Timeout: propagationQueryTimeout}
+	in, _, err := client.ExchangeContext(ctx, m, server)
+	if in != nil && in.Truncated {
+		client.Net = "tcp"
+		in, _, err = client.ExchangeContext(ctx, m, server)
+	}
+	return in, err
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"easyacme/internal/config"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRenewalInterval = 6 * time.Hour
+	defaultRenewBeforeDays = 30
+)
+
+// RenewalService 证书自动续期服务，后台定期检查即将到期的证书并重新签发
+type RenewalService interface {
+	Start()
+	Stop()
+	RunOnce(ctx context.Context)
+}
+
+type RenewalServiceImpl struct {
+	logger          *zap.Logger
+	acmeCertService AcmeCertService
+	enabled         bool
+	interval        time.Duration
+	renewBeforeDays int
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRenewalService .
+func NewRenewalService(cfg *config.Config, logger *zap.Logger, acmeCertService AcmeCertService) RenewalService {
+	interval := time.Duration(cfg.Renewal.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = defaultRenewalInterval
+	}
+	renewBeforeDays := cfg.Renewal.RenewBeforeDays
+	if renewBeforeDays <= 0 {
+		renewBeforeDays = defaultRenewBeforeDays
+	}
+	return &RenewalServiceImpl{
+		logger:          logger,
+		acmeCertService: acmeCertService,
+		enabled:         cfg.Renewal.Enabled,
+		interval:        interval,
+		renewBeforeDays: renewBeforeDays,
+	}
+}
+
+// Start 启动后台续期循环，启动后立即执行一次检查
+func (r *RenewalServiceImpl) Start() {
+	if !r.enabled {
+		r.logger.Info("Certificate renewal is disabled")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		r.RunOnce(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.RunOnce(ctx)
+			}
+		}
+	}()
+	r.logger.Info("Certificate renewal started",
+		zap.Duration("interval", r.interval), zap.Int("renew_before_days", r.renewBeforeDays))
+}
+
+// Stop 停止续期循环并等待正在进行的检查结束
+func (r *RenewalServiceImpl) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+	r.logger.Info("Certificate renewal stopped")
+}
+
+// RunOnce 先刷新证书的ARI续期窗口，再查找进入续期窗口的证书并逐个续期，单个证书失败不影响其他证书
+func (r *RenewalServiceImpl) RunOnce(ctx context.Context) {
+	r.refreshRenewalInfo(ctx)
+
+	certs, err := r.acmeCertService.GetCertsDueForRenewal(ctx, r.renewBeforeDays)
+	if err != nil {
+		r.logger.Error("failed to get certs due for renewal", zap.Error(err))
+		return
+	}
+
+	// 证书对由 RenewCert 一起续期，同一对只需处理一次
+	renewedPairs := make(map[string]bool)
+	for _, cert := range certs {
+		if ctx.Err() != nil {
+			return
+		}
+		if cert.PairID != "" {
+			if renewedPairs[cert.PairID] {
+				continue
+			}
+			renewedPairs[cert.PairID] = true
+		}
+		r.logger.Info("Renewing certificate", zap.String("cert_id", cert.ID), zap.Strings("domains", cert.Domains))
+		err := r.acmeCertService.RenewCert(ctx, &RenewCertReq{ID: cert.ID, IfVersion: cert.ActiveVersionID})
+		switch {
+		case errors.Is(err, ErrCertRenewing):
+			r.logger.Info("Certificate is being renewed elsewhere, skipped", zap.String("cert_id", cert.ID))
+		case err != nil:
+			r.logger.Error("failed to renew cert", zap.String("cert_id", cert.ID), zap.Error(err))
+		}
+	}
+}
+
+// refreshRenewalInfo 为到达查询时间的证书刷新CA建议的续期窗口
+func (r *RenewalServiceImpl) refreshRenewalInfo(ctx context.Context) {
+	certs, err := r.acmeCertService.GetCertsDueForRenewalInfo(ctx)
+	if err != nil {
+		r.logger.Error("failed to get certs due for renewal info", zap.Error(err))
+		return
+	}
+
+	for _, cert := range certs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := r.acmeCertService.RefreshRenewalInfo(ctx, &RefreshRenewalInfoReq{ID: cert.ID}); err != nil {
+			r.logger.Warn("failed to refresh renewal info", zap.String("cert_id", cert.ID), zap.Error(err))
+		}
+	}
+}
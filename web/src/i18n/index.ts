@@ -135,6 +135,10 @@ const resources = {
         "unknown": "Unknown",
         "deactivateSuccess": "Account deactivated successfully",
         "deactivateFailed": "Failed to deactivate account: ",
+        "termsChanged": "ToS changed",
+        "confirmAgreeTerms": "The CA has published new terms of service. Agree to them for this account?",
+        "agreeTermsSuccess": "Terms of service agreed",
+        "agreeTermsFailed": "Failed to agree to terms of service: ",
         "batchDeleteSuccess": "Successfully deleted {{count}} accounts",
         "batchDeleteFailed": "Batch delete failed: ",
         "batchDeactivateSuccess": "Successfully deactivated {{count}} accounts",
@@ -174,7 +178,16 @@ const resources = {
         "downloadPrivateKey": "Download Private Key",
         "revokeCert": "Revoke Certificate",
         "revokeConfirmation": "Are you sure you want to revoke this certificate? This action cannot be undone.",
+        "revokeReasonNone": "No reason",
+        "revokeReasonKeyCompromise": "Key compromise",
+        "revokeReasonSuperseded": "Superseded",
+        "revokeReasonCessationOfOperation": "Cessation of operation",
+        "revokeReasonAffiliationChanged": "Affiliation changed",
+        "revokeReasonUnspecified": "Unspecified",
+        "revokeWithCertKey": "Sign with the certificate's private key (when the issuing account is unavailable)",
+        "revokeServer": "CA directory URL (defaults to the issuing account's CA)",
         "onlyIssuedCanBeRevoked": "Only issued certificates can be revoked",
+        "external": "External",
         "confirm": "Confirm",
         "cancel": "Cancel",
         "delete": "Delete",
@@ -291,6 +304,7 @@ const resources = {
         "update": "Edit",
         "readKey": "View Private Key",
         "readSecret": "View Secret Key",
+        "import": "Import",
         "items": "items",
         "modules": {
           "dashboard": "Dashboard",
@@ -304,6 +318,8 @@ const resources = {
           "account": "Account Management",
           "cert": "Certificate Management",
           "provider": "Provider Management",
+          "domain": "Domain Management",
+          "import": "Bulk Import",
           "user": "User Management",
           "role": "Role Management",
           "permission": "System Permissions"
@@ -443,6 +459,10 @@ const resources = {
         "unknown": "未知",
         "deactivateSuccess": "账户吊销成功",
         "deactivateFailed": "账户吊销失败: ",
+        "termsChanged": "服务条款已变更",
+        "confirmAgreeTerms": "CA已发布新的服务条款，确定为此账户同意新条款吗？",
+        "agreeTermsSuccess": "已同意服务条款",
+        "agreeTermsFailed": "同意服务条款失败: ",
         "batchDeleteSuccess": "成功删除 {{count}} 个账户",
         "batchDeleteFailed": "批量删除失败: ",
         "batchDeactivateSuccess": "成功吊销 {{count}} 个账户",
@@ -482,7 +502,16 @@ const resources = {
         "downloadPrivateKey": "下载私钥",
         "revokeCert": "吊销证书",
         "revokeConfirmation": "确定要吊销此证书吗？此操作不可恢复。",
+        "revokeReasonNone": "不提交原因",
+        "revokeReasonKeyCompromise": "私钥泄露",
+        "revokeReasonSuperseded": "已被替换",
+        "revokeReasonCessationOfOperation": "停止使用",
+        "revokeReasonAffiliationChanged": "归属变更",
+        "revokeReasonUnspecified": "未指定",
+        "revokeWithCertKey": "使用证书私钥签名（签发账户不可用时）",
+        "revokeServer": "CA目录地址（为空时使用签发账户的CA）",
         "onlyIssuedCanBeRevoked": "只有已签发的证书才能吊销",
+        "external": "外部证书",
         "confirm": "确定",
         "cancel": "取消",
         "delete": "删除",
@@ -599,6 +628,7 @@ const resources = {
         "update": "编辑",
         "readKey": "查看私钥",
         "readSecret": "查看密钥",
+        "import": "导入",
         "items": "项",
         "modules": {
           "dashboard": "看板",
@@ -612,6 +642,8 @@ const resources = {
           "account": "账户管理",
           "cert": "证书管理",
           "provider": "提供商管理",
+          "domain": "域名管理",
+          "import": "批量导入",
           "user": "用户管理",
           "role": "角色管理",
           "permission": "系统权限"
@@ -423,10 +423,44 @@ export const ACMEList = () => {
                         dataIndex="status"
                         title={t('acmeAccountPage.status')}
                         width={100}
-                        render={(value: string) => (
-                            <Tag color={(statusMap as Record<string, any>)[value]?.color || "default"}>
-                                {(statusMap as Record<string, any>)[value]?.label || value}
-                            </Tag>
+                        render={(value: string, record: BaseRecord) => (
+                            <Space size={4} wrap>
+                                <Tag color={(statusMap as Record<string, any>)[value]?.color || "default"}>
+                                    {(statusMap as Record<string, any>)[value]?.label || value}
+                                </Tag>
+                                {record.terms_changed && (
+                                    <Popconfirm
+                                        title={t('acmeAccountPage.termsChanged')}
+                                        description={
+                                            <div>
+                                                <div>{t('acmeAccountPage.confirmAgreeTerms')}</div>
+                                                <a href={record.current_terms_of_service} target="_blank" rel="noreferrer">
+                                                    {record.current_terms_of_service}
+                                                </a>
+                                            </div>
+                                        }
+                                        onConfirm={async () => {
+                                            try {
+                                                await mutateAsync({
+                                                    url: `${API_BASE_URL}/acme/accounts/${record.id}/agree-tos`,
+                                                    method: "post",
+                                                    values: {},
+                                                });
+                                                message.success(t('acmeAccountPage.agreeTermsSuccess'));
+                                                tableQueryResult.refetch();
+                                            } catch (error) {
+                                                message.error(t('acmeAccountPage.agreeTermsFailed') + (error as any).message);
+                                            }
+                                        }}
+                                        okText={t('acmeAccountPage.yesOption')}
+                                        cancelText={t('acmeAccountPage.noOption')}
+                                    >
+                                        <Tag color="orange" style={{ cursor: 'pointer' }}>
+                                            {t('acmeAccountPage.termsChanged')}
+                                        </Tag>
+                                    </Popconfirm>
+                                )}
+                            </Space>
                         )}
                     />
 
@@ -28,6 +28,7 @@ export const CertApplyModal: React.FC<CertApplyModalProps> = ({ visible, onClose
     const [verifyMode, setVerifyMode] = useState<'manual' | 'auto'>('manual');
     const [authData, setAuthData] = useState<any>(null);
     const [dnsProviderSelected, setDnsProviderSelected] = useState<string>('');
+    const [dnsRecords, setDnsRecords] = useState<any[]>([]);
     const { t } = useTranslation();
 
     // 获取 ACME 账户列表
@@ -113,16 +114,38 @@ export const CertApplyModal: React.FC<CertApplyModalProps> = ({ visible, onClose
                 body: JSON.stringify(requestData),
             });
 
-            if (response.ok) {
-                message.success(t('acmeCertPage.applySuccess'));
-                setLoading(false);
-                onSuccess && onSuccess();
-                onClose();
-            } else {
+            if (!response.ok) {
                 const errorData = await response.json();
                 message.error(t('acmeCertPage.applyFailed') + ": " + (errorData.error || t('acmeCertPage.unknownError')));
                 setLoading(false);
+                return;
             }
+
+            // 签发在后台任务中执行，轮询任务状态直到结束
+            const { job_id } = await response.json();
+            for (;;) {
+                await new Promise(resolve => setTimeout(resolve, 3000));
+                const jobResponse = await fetch(`${API_BASE_URL}/acme/jobs/${job_id}`, {
+                    credentials: 'include',
+                });
+                const job = await jobResponse.json();
+                setDnsRecords(job.dns_records || []);
+                if (!jobResponse.ok) {
+                    message.error(t('acmeCertPage.applyFailed') + ": " + (job.error || t('acmeCertPage.unknownError')));
+                    break;
+                }
+                if (job.state === 'succeeded') {
+                    message.success(t('acmeCertPage.applySuccess'));
+                    onSuccess && onSuccess();
+                    onClose();
+                    break;
+                }
+                if (job.state === 'failed' || job.state === 'cancelled') {
+                    message.error(t('acmeCertPage.applyFailed') + ": " + (job.error || t('acmeCertPage.unknownError')));
+                    break;
+                }
+            }
+            setLoading(false);
         } catch (error: any) {
             message.error(t('acmeCertPage.applyFailed') + ": " + error.message);
             setLoading(false);
@@ -155,6 +178,27 @@ export const CertApplyModal: React.FC<CertApplyModalProps> = ({ visible, onClose
         }
     ];
 
+    // TXT记录在各权威DNS服务器上的传播状态
+    const propagationColumns = [
+        { title: 'FQDN', dataIndex: 'fqdn', key: 'fqdn' },
+        {
+            title: 'Nameservers',
+            key: 'nameservers',
+            render: (_: any, record: any) => (record.nameservers || []).map((ns: any) => (
+                <div key={ns.nameserver}>
+                    <Text type={ns.found ? 'success' : 'warning'}>{ns.nameserver} {ns.found ? '✓' : (ns.error || '…')}</Text>
+                </div>
+            )),
+        },
+        {
+            title: 'Status',
+            key: 'propagated',
+            render: (_: any, record: any) => record.propagated
+                ? <Text type="success">propagated</Text>
+                : <Text type="warning">{record.error || 'pending'}</Text>,
+        },
+    ];
+
     // 检查按钮是否应该禁用
     const isButtonDisabled = () => {
         if (loading) return true;
@@ -313,6 +357,7 @@ export const CertApplyModal: React.FC<CertApplyModalProps> = ({ visible, onClose
         setAuthData(null);
         setFormData({});
         setDnsProviderSelected('');
+        setDnsRecords([]);
         form1.resetFields();
         form2.resetFields();
         onClose();
@@ -332,6 +377,16 @@ export const CertApplyModal: React.FC<CertApplyModalProps> = ({ visible, onClose
                 {steps.map(item => <Step key={item.title} title={item.title} />)}
             </Steps>
             <div>{steps[current].content}</div>
+            {dnsRecords.length > 0 && (
+                <Table
+                    style={{ marginTop: 16 }}
+                    size="small"
+                    rowKey={(record: any) => record.fqdn + record.value}
+                    columns={propagationColumns}
+                    dataSource={dnsRecords}
+                    pagination={false}
+                />
+            )}
         </Modal>
     );
 };
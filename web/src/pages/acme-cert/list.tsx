@@ -6,7 +6,7 @@ import {
     DeleteButton,
     TagField,
 } from "@refinedev/antd";
-import { Table, Space, Tag, Button, Popconfirm, message, Input, Select, Form, Card, ConfigProvider, theme } from "antd";
+import { Table, Space, Tag, Button, Popconfirm, message, Input, Select, Form, Card, ConfigProvider, theme, Checkbox } from "antd";
 import zhCN from 'antd/locale/zh_CN';
 import { CertApply } from "./create";
 import { API_BASE_URL } from '../../config';
@@ -16,6 +16,9 @@ import { getStatusMap } from "./status";
 
 export const CertList = () => {
     const [localFilters, setLocalFilters] = useState<any[]>([]);
+    const [revokeReason, setRevokeReason] = useState<string>("");
+    const [revokeWithCertKey, setRevokeWithCertKey] = useState(false);
+    const [revokeServer, setRevokeServer] = useState<string>("");
     
     const { tableProps, tableQueryResult } = useTable({
         resource: "acme/certificates",
@@ -186,7 +189,7 @@ export const CertList = () => {
     };
 
     // 吊销证书
-    const handleRevokeCert = async (id: string) => {
+    const handleRevokeCert = async (id: string, external: boolean) => {
         try {
             const response = await fetch(`${API_BASE_URL}/acme/certificates/${id}/revoke`, {
                 method: "POST",
@@ -194,6 +197,7 @@ export const CertList = () => {
                     "Content-Type": "application/json",
                 },
                 credentials: 'include', // 重要：包含cookies和session信息
+                body: JSON.stringify({ reason: revokeReason, use_cert_key: revokeWithCertKey || external, server: revokeServer }),
             });
 
             if (!response.ok) {
@@ -439,10 +443,15 @@ export const CertList = () => {
                     dataIndex="cert_status" 
                     title={t('acmeCertPage.status')}
                     width="150px"
-                    render={(value: string) => (
-                        <Tag color={getStatusColor(value)}>
-                            {getStatusText(value)}
-                        </Tag>
+                    render={(value: string, record: BaseRecord) => (
+                        <Space size={4}>
+                            <Tag color={getStatusColor(value)}>
+                                {getStatusText(value)}
+                            </Tag>
+                            {record.source === 'external' && (
+                                <Tag>{t('acmeCertPage.external')}</Tag>
+                            )}
+                        </Space>
                     )}
                 />
                 
@@ -451,7 +460,9 @@ export const CertList = () => {
                     dataIndex="actions"
                     width="280px"
                     render={(_, record: BaseRecord) => {
-                        const canRevoke = record.cert_status === 'issued';
+                        // 外部证书没有签发账户，只能使用证书私钥并指定CA目录地址吊销
+                        const external = record.source === 'external';
+                        const canRevoke = record.cert_status === 'issued' || record.cert_status === 'expired';
                         
                         return (
                             <Space size={6}>
@@ -484,8 +495,45 @@ export const CertList = () => {
                                 {record.id && (
                                     <Popconfirm
                                         title={t('acmeCertPage.revokeCert')}
-                                        description={canRevoke ? t('acmeCertPage.revokeConfirmation') : t('acmeCertPage.onlyIssuedCanBeRevoked')}
-                                        onConfirm={() => canRevoke && handleRevokeCert(record.id as string)}
+                                        description={canRevoke ? (
+                                            <Space direction="vertical" size={8}>
+                                                <span>{t('acmeCertPage.revokeConfirmation')}</span>
+                                                <Select
+                                                    size="small"
+                                                    style={{ width: 240 }}
+                                                    value={revokeReason}
+                                                    onChange={setRevokeReason}
+                                                    options={[
+                                                        { value: "", label: t('acmeCertPage.revokeReasonNone') },
+                                                        { value: "keyCompromise", label: t('acmeCertPage.revokeReasonKeyCompromise') },
+                                                        { value: "superseded", label: t('acmeCertPage.revokeReasonSuperseded') },
+                                                        { value: "cessationOfOperation", label: t('acmeCertPage.revokeReasonCessationOfOperation') },
+                                                        { value: "affiliationChanged", label: t('acmeCertPage.revokeReasonAffiliationChanged') },
+                                                        { value: "unspecified", label: t('acmeCertPage.revokeReasonUnspecified') },
+                                                    ]}
+                                                />
+                                                <Checkbox checked={revokeWithCertKey || external} disabled={external} onChange={(e) => setRevokeWithCertKey(e.target.checked)}>
+                                                    {t('acmeCertPage.revokeWithCertKey')}
+                                                </Checkbox>
+                                                {(revokeWithCertKey || external) && (
+                                                    <Input
+                                                        size="small"
+                                                        style={{ width: 240 }}
+                                                        value={revokeServer}
+                                                        onChange={(e) => setRevokeServer(e.target.value)}
+                                                        placeholder={t('acmeCertPage.revokeServer')}
+                                                    />
+                                                )}
+                                            </Space>
+                                        ) : t('acmeCertPage.onlyIssuedCanBeRevoked')}
+                                        onOpenChange={(open) => {
+                                            if (open) {
+                                                setRevokeReason("");
+                                                setRevokeWithCertKey(false);
+                                                setRevokeServer("");
+                                            }
+                                        }}
+                                        onConfirm={() => canRevoke && handleRevokeCert(record.id as string, external)}
                                         okText={t('acmeCertPage.confirm')}
                                         cancelText={t('acmeCertPage.cancel')}
                                         disabled={!canRevoke}
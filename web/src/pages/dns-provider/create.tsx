@@ -16,6 +16,7 @@ export const DNSCreate = () => {
         { label: 'Cloudflare', value: 'cloudflare' },
         { label: 'GoDaddy', value: 'godaddy' },
         { label: 'AWS Route53', value: 'route53' },
+        { label: 'acme-dns', value: 'acmedns' },
     ];
 
     // 获取授权字段的键名
@@ -63,6 +64,12 @@ export const DNSCreate = () => {
                 idPlaceholder: '请输入AWS Access Key ID',
                 keyPlaceholder: '请输入AWS Secret Access Key'
             },
+            'acmedns': { 
+                idKey: 'ACMEDNS_USERNAME', 
+                keyKey: 'ACMEDNS_PASSWORD',
+                idPlaceholder: '请输入 acme-dns 注册返回的 username',
+                keyPlaceholder: '请输入 acme-dns 注册返回的 password'
+            },
         };
         return keyMap[type] || { 
             idKey: 'SECRET_ID', 
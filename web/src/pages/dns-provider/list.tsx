@@ -78,6 +78,7 @@ export const DNSList = () => {
             'cloudflare': { name: 'Cloudflare', color: 'geekblue' },
             'godaddy': { name: 'GoDaddy', color: 'green' },
             'route53': { name: 'AWS Route53', color: 'cyan' },
+            'acmedns': { name: 'acme-dns', color: 'geekblue' },
         };
         return typeMap[type] || { name: type || t('dnsProviderPage.unknown'), color: 'default' };
     };
@@ -92,6 +93,7 @@ export const DNSList = () => {
             'cloudflare': { idKey: 'CLOUDFLARE_API_TOKEN', keyKey: 'CLOUDFLARE_ZONE_ID' },
             'godaddy': { idKey: 'GODADDY_API_KEY', keyKey: 'GODADDY_API_SECRET' },
             'route53': { idKey: 'AWS_ACCESS_KEY_ID', keyKey: 'AWS_SECRET_ACCESS_KEY' },
+            'acmedns': { idKey: 'ACMEDNS_USERNAME', keyKey: 'ACMEDNS_PASSWORD' },
         };
         return keyMap[type] || { idKey: 'SECRET_ID', keyKey: 'SECRET_KEY' };
     };
@@ -121,6 +123,7 @@ export const DNSList = () => {
         { label: <Tag color="geekblue">Cloudflare</Tag>, value: 'cloudflare' },
         { label: <Tag color="green">GoDaddy</Tag>, value: 'godaddy' },
         { label: <Tag color="cyan">AWS Route53</Tag>, value: 'route53' },
+        { label: <Tag color="geekblue">acme-dns</Tag>, value: 'acmedns' },
     ];
 
     // 处理厂商类型筛选
@@ -103,6 +103,7 @@ export const DNSShow = () => {
             'cloudflare': { name: 'Cloudflare', color: 'geekblue' },
             'godaddy': { name: 'GoDaddy', color: 'green' },
             'route53': { name: 'AWS Route53', color: 'cyan' },
+            'acmedns': { name: 'acme-dns', color: 'geekblue' },
         };
         return typeMap[type] || { name: type || t('dnsProviderPage.unknown'), color: 'default' };
     };
@@ -117,6 +118,7 @@ export const DNSShow = () => {
             'cloudflare': { idKey: 'CLOUDFLARE_API_TOKEN', keyKey: 'CLOUDFLARE_ZONE_ID' },
             'godaddy': { idKey: 'GODADDY_API_KEY', keyKey: 'GODADDY_API_SECRET' },
             'route53': { idKey: 'AWS_ACCESS_KEY_ID', keyKey: 'AWS_SECRET_ACCESS_KEY' },
+            'acmedns': { idKey: 'ACMEDNS_USERNAME', keyKey: 'ACMEDNS_PASSWORD' },
         };
         return keyMap[type] || { idKey: 'SECRET_ID', keyKey: 'SECRET_KEY' };
     };
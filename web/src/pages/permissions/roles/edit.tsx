@@ -19,6 +19,7 @@ const getActionMap = (t: any): { [key: string]: string } => ({
     "update": t('rolePage.update'),
     "read_key": t('rolePage.readKey'),
     "read_secret": t('rolePage.readSecret'),
+    "import": t('rolePage.import'),
 });
 
 const createPermissionTree = (t: any) => [
@@ -38,7 +39,8 @@ const createPermissionTree = (t: any) => [
                 { value: 'acme:cert:delete', action: 'delete' }, { value: 'acme:cert:auth', action: 'auth' },
                 { value: 'acme:cert:manage', action: 'manage' },
                 { value: 'acme:cert:private_key:read', action: 'read_key' }
-            ]}
+            ]},
+            { name: t('rolePage.features.import'), key: 'acme:import', permissions: [{ value: 'acme:import', action: 'import' }] }
         ]
     },
     {
@@ -47,6 +49,10 @@ const createPermissionTree = (t: any) => [
                 { value: 'dns:provider:create', action: 'create' }, { value: 'dns:provider:read', action: 'read' },
                 { value: 'dns:provider:update', action: 'update' }, { value: 'dns:provider:delete', action: 'delete' },
                 { value: 'dns:provider:secret:read', action: 'read_secret' },
+            ]},
+            { name: t('rolePage.features.domain'), key: 'dns:domain', permissions: [
+                { value: 'dns:domain:create', action: 'create' }, { value: 'dns:domain:read', action: 'read' },
+                { value: 'dns:domain:update', action: 'update' }, { value: 'dns:domain:delete', action: 'delete' },
             ]}
         ]
     },